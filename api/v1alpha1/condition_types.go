@@ -0,0 +1,74 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+const (
+	// ReadyCondition indicates that the repository was polled successfully.
+	ReadyCondition = "Ready"
+	// ReconcilingCondition indicates that the controller is still working
+	// towards a successful poll e.g. retrying after a transient error.
+	ReconcilingCondition = "Reconciling"
+	// StalledCondition indicates that the repository can't be polled until
+	// the configuration or credentials are fixed.
+	StalledCondition = "Stalled"
+	// EventDeliveredCondition indicates whether the event for the last change
+	// was delivered to the endpoint.
+	EventDeliveredCondition = "EventDelivered"
+	// SuspendedCondition indicates that polling is suspended.
+	SuspendedCondition = "Suspended"
+)
+
+// These are the reasons for the Ready condition.
+const (
+	// SucceededReason is used when the poll succeeded.
+	SucceededReason = "Succeeded"
+	// NotFoundReason is used when the repository or ref doesn't exist, or
+	// the credentials can't access it.
+	NotFoundReason = "NotFound"
+	// UnauthorizedReason is used when the credentials are missing or invalid.
+	UnauthorizedReason = "Unauthorized"
+	// ForbiddenReason is used when the credentials don't allow access.
+	ForbiddenReason = "Forbidden"
+	// RateLimitedReason is used when the rate limit has been exceeded.
+	RateLimitedReason = "RateLimited"
+	// ServerErrorReason is used when the server failed to handle the request.
+	ServerErrorReason = "ServerError"
+	// TimeoutReason is used when the request timed out.
+	TimeoutReason = "Timeout"
+	// MalformedResponseReason is used when the response can't be parsed.
+	MalformedResponseReason = "MalformedResponse"
+	// PollFailedReason is used for other poll failures.
+	PollFailedReason = "PollFailed"
+	// ConfigurationErrorReason is used when the repository can't be polled
+	// because of the configuration e.g. the type can't be detected, or the
+	// credentials can't be read.
+	ConfigurationErrorReason = "ConfigurationError"
+)
+
+// These are the reasons for the EventDelivered condition.
+const (
+	// DeliveredReason is used when the event was delivered.
+	DeliveredReason = "Delivered"
+	// DeliveryFailedReason is used when the event couldn't be delivered.
+	DeliveryFailedReason = "DeliveryFailed"
+)
+
+// These are the reasons for the Suspended condition.
+const (
+	// SuspendedReason is used when the spec suspends polling.
+	SuspendedReason = "Suspended"
+)
@@ -22,31 +22,87 @@ import (
 )
 
 // RepoType defines the protocol to use to talk to the upstream server.
-// +kubebuilder:validation:Enum=github;gitlab
+// +kubebuilder:validation:Enum=github;gitlab;gitea;bitbucket;bitbucketserver;azuredevops;gerrit;git
 type RepoType string
 
 const (
-	GitHub RepoType = "github"
-	GitLab RepoType = "gitlab"
+	GitHub    RepoType = "github"
+	GitLab    RepoType = "gitlab"
+	Gitea     RepoType = "gitea"
+	Bitbucket RepoType = "bitbucket"
+	// BitbucketServer is Bitbucket Server and Bitbucket Data Center.
+	BitbucketServer RepoType = "bitbucketserver"
+	AzureDevOps     RepoType = "azuredevops"
+	Gerrit          RepoType = "gerrit"
+	// Git is any Git server that supports the smart HTTP protocol, or SSH.
+	Git RepoType = "git"
 )
 
+// RequestedAtAnnotation requests a poll when the value changes, the value is
+// typically the current time.
+const RequestedAtAnnotation = "polling.gitops.tools/requestedAt"
+
 // PolledRepositorySpec defines the desired state of PolledRepository
+// +kubebuilder:validation:XValidation:rule="has(self.minFrequency) == has(self.maxFrequency)",message="minFrequency and maxFrequency must be provided together"
+// +kubebuilder:validation:XValidation:rule="!has(self.minFrequency) || duration(self.minFrequency) <= duration(self.maxFrequency)",message="minFrequency must not be greater than maxFrequency"
+// +kubebuilder:validation:XValidation:rule="!has(self.minFrequency) || duration(self.minFrequency) > duration('0s')",message="minFrequency must be greater than 0s"
+// +kubebuilder:validation:XValidation:rule="!has(self.maxFrequency) || duration(self.maxFrequency) > duration('0s')",message="maxFrequency must be greater than 0s"
+// +kubebuilder:validation:XValidation:rule="has(self.ref) || has(self.tagPolicy) || has(self.refs) || has(self.pullRequests) || has(self.releases)",message="one of ref, refs, tagPolicy, pullRequests or releases must be provided"
+// +kubebuilder:validation:XValidation:rule="[has(self.refs), has(self.tagPolicy), has(self.pullRequests), has(self.releases)].filter(x, x).size() <= 1",message="refs, tagPolicy, pullRequests and releases are mutually exclusive"
 type PolledRepositorySpec struct {
 	// URL is the Git repository URL to poll.
-	// +kubebuilder:validation:Pattern="^https://"
+	// ssh:// and scp-style URLs e.g. git@example.com:org/repo.git are only
+	// supported for the git type.
+	// +kubebuilder:validation:Pattern="^(https://|ssh://|[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:)"
 	// +required
 	URL string `json:"url"`
 
-	// Ref is the branch or tag to poll within the repository.
-	// +required
+	// Ref is the branch or tag to poll within the repository, this is not
+	// used when Refs or a TagPolicy are provided.
+	// +optional
 	Ref string `json:"ref,omitempty"`
 
+	// Refs are patterns for the branches to poll, each branch that matches is
+	// polled, and an event is dispatched for each branch that changes.
+	// Patterns are globs e.g. "release/*", where * doesn't match a /, or
+	// regular expressions with a "regex:" prefix e.g. "regex:^release-[0-9]+$".
+	// This is only supported for the github, gitlab and git types.
+	// +optional
+	Refs []string `json:"refs,omitempty"`
+
+	// PullRequests polls the open pull requests, or merge requests, instead
+	// of the Ref, and dispatches events when they're opened, updated with a
+	// new head commit, and closed or merged.
+	// This is only supported for the github and gitlab types.
+	// +optional
+	PullRequests *PullRequestPolicy `json:"pullRequests,omitempty"`
+
+	// TagPolicy polls the tags in the repository instead of the Ref, and
+	// selects a tag with the policy, events are only dispatched when the
+	// selected tag changes.
+	// This is only supported for the github and gitlab types.
+	// +optional
+	TagPolicy *TagPolicy `json:"tagPolicy,omitempty"`
+
+	// Releases polls the releases in the repository instead of the Ref, and
+	// dispatches a release event when a new release is published.
+	// This is only supported for the github and gitlab types.
+	// +optional
+	Releases *ReleasePolicy `json:"releases,omitempty"`
+
 	// Auth provides an optional secret for polling the repository.
 	// +optional
 	Auth *AuthSecret `json:"auth,omitempty"`
 
+	// APIURL overrides the API endpoint that is derived from the URL e.g.
+	// https://github.example.com/api/v3 for GitHub Enterprise Server.
+	// +kubebuilder:validation:Pattern="^(http|https)://"
+	// +optional
+	APIURL string `json:"apiURL,omitempty"`
+
 	// Type is the protocol to use to access the repository.
-	// +kubebuilder:validation:Enum=github;gitlab
+	// If this is not provided, the type is detected from the URL.
+	// +kubebuilder:validation:Enum=github;gitlab;gitea;bitbucket;bitbucketserver;azuredevops;gerrit;git
 	Type RepoType `json:"type,omitempty"`
 
 	// Frequency is how often to poll this repository.
@@ -54,31 +110,241 @@ type PolledRepositorySpec struct {
 	// +required
 	Frequency *metav1.Duration `json:"frequency,omitempty"`
 
+	// MinFrequency and MaxFrequency enable adaptive polling, instead of the
+	// Frequency, the repository is polled at the MinFrequency after a change,
+	// and the frequency doubles with each poll that finds no change, up to the
+	// MaxFrequency.
+	// +optional
+	MinFrequency *metav1.Duration `json:"minFrequency,omitempty"`
+
+	// MaxFrequency is the longest time between polls with adaptive polling.
+	// +optional
+	MaxFrequency *metav1.Duration `json:"maxFrequency,omitempty"`
+
+	// Schedule polls the repository at the times from the cron expressions
+	// instead of the Frequency, the next poll is the earliest time from any
+	// of the expressions.
+	// +optional
+	Schedule []PollSchedule `json:"schedule,omitempty"`
+
+	// Windows restrict when the repository can be polled, if there are any
+	// allow windows, polls are only made during them, and polls are never
+	// made during deny windows.
+	// +optional
+	Windows []PollWindow `json:"windows,omitempty"`
+
 	// The notification URL, this is where CloudEvents are dispatched to for
 	// this repository.
 	// +kubebuilder:validation:Pattern="^(http|https)://"
 	// +required
 	Endpoint string `json:"endpoint"`
 
+	// Suspend stops polling and dispatching events for this repository.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DispatchOnResume dispatches an event when polling resumes if the ref
+	// changed while polling was suspended, otherwise the change is recorded
+	// without dispatching an event.
+	// +optional
+	DispatchOnResume bool `json:"dispatchOnResume,omitempty"`
+
 	// TODO: Retries...guarantees around delivery?
 	// Errors in delivery will cause rereconciliation?
 }
 
+// PollSchedule is a cron expression for the times to poll.
+type PollSchedule struct {
+	// Cron is a standard five field cron expression e.g. "* 9-17 * * 1-5",
+	// or a descriptor e.g. "@hourly".
+	// +required
+	Cron string `json:"cron"`
+
+	// TimeZone is the IANA time zone for the cron expression, this defaults
+	// to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// TagPolicy selects a tag from the tags in the repository.
+//
+// The Pattern filters the tags, and the tag is selected from the remaining
+// tags by the SemVer range, or the creation date if Latest is true, otherwise
+// the highest tag in alphabetical order is selected.
+// +kubebuilder:validation:XValidation:rule="has(self.semver) || has(self.pattern) || (has(self.latest) && self.latest)",message="one of semver, pattern or latest must be provided"
+// +kubebuilder:validation:XValidation:rule="!has(self.semver) || !has(self.latest) || !self.latest",message="semver and latest are mutually exclusive"
+type TagPolicy struct {
+	// SemVer is a semantic version range e.g. ">=1.2.0 <2.0.0", the highest
+	// version in the range is selected, tags that aren't versions are
+	// ignored.
+	// +optional
+	SemVer string `json:"semver,omitempty"`
+
+	// Pattern is a regular expression that tags must match e.g. "^v1\\.".
+	// +optional
+	Pattern string `json:"pattern,omitempty"`
+
+	// Latest selects the most recently created tag, this is the date of the
+	// tagger for annotated tags, and the commit date for lightweight tags.
+	// +optional
+	Latest bool `json:"latest,omitempty"`
+}
+
+// ReleasePolicy filters the releases to poll, the newest release that isn't
+// excluded is selected.
+type ReleasePolicy struct {
+	// ExcludeDrafts ignores draft releases, drafts are only listed for
+	// tokens that can push to the repository.
+	// +optional
+	ExcludeDrafts bool `json:"excludeDrafts,omitempty"`
+
+	// ExcludePrereleases ignores releases that are marked as prereleases, or
+	// upcoming releases for GitLab.
+	// +optional
+	ExcludePrereleases bool `json:"excludePrereleases,omitempty"`
+}
+
+// PullRequestPolicy filters the pull requests to poll.
+type PullRequestPolicy struct {
+	// Labels are the labels that pull requests must have, pull requests must
+	// have all of the labels.
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+
+	// TargetBranches are patterns for the branches that pull requests must
+	// target, in the same format as the Refs, if none are provided pull
+	// requests for any branch are polled.
+	// +optional
+	TargetBranches []string `json:"targetBranches,omitempty"`
+}
+
+// WindowType is the type of a PollWindow.
+// +kubebuilder:validation:Enum=allow;deny
+type WindowType string
+
+const (
+	// AllowWindow allows polling during the window.
+	AllowWindow WindowType = "allow"
+	// DenyWindow blocks polling during the window.
+	DenyWindow WindowType = "deny"
+)
+
+// PollWindow is a daily time range when polling is allowed or denied.
+type PollWindow struct {
+	// Type is whether polling is allowed or denied during the window.
+	// +required
+	Type WindowType `json:"type"`
+
+	// Start is the time of day that the window starts e.g. "09:00".
+	// +kubebuilder:validation:Pattern="^([01][0-9]|2[0-3]):[0-5][0-9]$"
+	// +required
+	Start string `json:"start"`
+
+	// End is the time of day that the window ends e.g. "17:30", if this is
+	// before the Start, the window ends the next day, and if it's the same as
+	// the Start, the window lasts for the whole day.
+	// +kubebuilder:validation:Pattern="^([01][0-9]|2[0-3]):[0-5][0-9]$"
+	// +required
+	End string `json:"end"`
+
+	// Days are the days of the week that the window starts on e.g. "Mon",
+	// if no days are provided, the window applies every day.
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+
+	// TimeZone is the IANA time zone for the window, this defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// Weekday is a day of the week.
+// +kubebuilder:validation:Enum=Sun;Mon;Tue;Wed;Thu;Fri;Sat
+type Weekday string
+
 // AuthSecret references a secret for authenticating the request.
 type AuthSecret struct {
 	// This is a local reference to the named secret to fetch.
-	// This secret is expected to have a "token" key with a valid GitHub/GitLab
-	// auth token.
+	// This secret is expected to have a "token" key with a valid
+	// GitHub/GitLab/Gitea auth token.
 	SecretRef corev1.LocalObjectReference `json:"secretRef,omitempty"`
 	//+kubebuilder:default:="token"
 	Key string `json:"key,omitempty"`
+
+	// UsernameKey is an optional key in the secret with a username.
+	// When this is provided, the username and the value of Key are used for
+	// basic authentication e.g. Bitbucket app passwords.
+	// +optional
+	UsernameKey string `json:"usernameKey,omitempty"`
+
+	// IdentityKey is the key in the secret with the SSH private key, this is
+	// only used for SSH URLs, and defaults to "identity".
+	// +optional
+	IdentityKey string `json:"identityKey,omitempty"`
+
+	// KnownHostsKey is the key in the secret with the known_hosts for the SSH
+	// server, this is only used for SSH URLs, and defaults to "known_hosts".
+	// +optional
+	KnownHostsKey string `json:"knownHostsKey,omitempty"`
+
+	// GitHubApp authenticates as a GitHub App installation, this is only
+	// supported for the github type.
+	// The secret is expected to have "githubAppID", "githubAppInstallationID"
+	// and "githubAppPrivateKey" keys, the private key is PEM encoded.
+	// +optional
+	GitHubApp bool `json:"githubApp,omitempty"`
 }
 
 // PolledRepositoryStatus defines the observed state of PolledRepository
 type PolledRepositoryStatus struct {
-	PollStatus         `json:"pollStatus,omitempty"`
-	LastError          string `json:"lastError,omitempty"`
-	ObservedGeneration int64  `json:"observedGeneration,omitempty"`
+	PollStatus `json:"pollStatus,omitempty"`
+	LastError  string `json:"lastError,omitempty"`
+
+	// Refs is the last polled state of each branch that matches the Refs,
+	// keyed by the branch name.
+	// +optional
+	Refs map[string]PollStatus `json:"refs,omitempty"`
+
+	// PullRequests is the head SHA of each open pull request that matches
+	// the PullRequests policy, keyed by the number.
+	// +optional
+	PullRequests map[string]string `json:"pullRequests,omitempty"`
+
+	// ObservedGeneration is the generation of the spec that was last
+	// reconciled.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastPollTime is when the repository was last polled successfully.
+	// +optional
+	LastPollTime *metav1.Time `json:"lastPollTime,omitempty"`
+
+	// LastChangeTime is when a change to the ref was last detected.
+	// +optional
+	LastChangeTime *metav1.Time `json:"lastChangeTime,omitempty"`
+
+	// NextPollTime is when the repository will next be polled.
+	// +optional
+	NextPollTime *metav1.Time `json:"nextPollTime,omitempty"`
+
+	// EffectiveFrequency is the current frequency with adaptive polling.
+	// +optional
+	EffectiveFrequency *metav1.Duration `json:"effectiveFrequency,omitempty"`
+
+	// LastHandledRequestedAt is the value of the RequestedAtAnnotation when
+	// the last requested poll was handled.
+	// +optional
+	LastHandledRequestedAt string `json:"lastHandledRequestedAt,omitempty"`
+
+	// DetectedType is the type of the repository that was detected from the
+	// URL when no type is provided.
+	// +optional
+	DetectedType RepoType `json:"detectedType,omitempty"`
+
+	// Conditions are the latest observations of the repository's state.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // PollStatus represents the last polled state of the repo.
@@ -86,19 +352,33 @@ type PollStatus struct {
 	Ref  string `json:"ref"`
 	SHA  string `json:"sha"`
 	ETag string `json:"etag"`
+
+	// Tag is the tag that was selected by the TagPolicy, or the tag of the
+	// release that was selected by the Releases policy.
+	// +optional
+	Tag string `json:"tag,omitempty"`
+
+	// Published is when the release that was selected by the Releases policy
+	// was published, older releases aren't dispatched.
+	// +optional
+	Published *metav1.Time `json:"published,omitempty"`
 }
 
 // Equal returns true if two PollStatus values match.
 func (p PollStatus) Equal(o PollStatus) bool {
-	return (p.Ref == o.Ref) && (p.SHA == o.SHA) && (p.ETag == o.ETag)
+	return (p.Ref == o.Ref) && (p.SHA == o.SHA) && (p.ETag == o.ETag) && (p.Tag == o.Tag) && p.Published.Equal(o.Published)
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.spec.url`
 //+kubebuilder:printcolumn:name="Ref",type=string,JSONPath=".status.pollStatus.ref",description=""
+//+kubebuilder:printcolumn:name="Tag",type=string,JSONPath=".status.pollStatus.tag",priority=1,description=""
 //+kubebuilder:printcolumn:name="SHA",type=string,JSONPath=".status.pollStatus.sha",description=""
+//+kubebuilder:printcolumn:name="Suspended",type=boolean,JSONPath=".spec.suspend",description=""
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
 //+kubebuilder:printcolumn:name="Error",type=string,JSONPath=".status.lastError",description=""
+//+kubebuilder:printcolumn:name="Last Poll",type=date,JSONPath=".status.lastPollTime",description=""
 
 // PolledRepository is the Schema for the polledrepositories API
 type PolledRepository struct {
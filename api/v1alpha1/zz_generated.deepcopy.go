@@ -41,9 +41,28 @@ func (in *AuthSecret) DeepCopy() *AuthSecret {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PollSchedule) DeepCopyInto(out *PollSchedule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PollSchedule.
+func (in *PollSchedule) DeepCopy() *PollSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(PollSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PollStatus) DeepCopyInto(out *PollStatus) {
 	*out = *in
+	if in.Published != nil {
+		in, out := &in.Published, &out.Published
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PollStatus.
@@ -56,13 +75,33 @@ func (in *PollStatus) DeepCopy() *PollStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PollWindow) DeepCopyInto(out *PollWindow) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]Weekday, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PollWindow.
+func (in *PollWindow) DeepCopy() *PollWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(PollWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PolledRepository) DeepCopyInto(out *PolledRepository) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolledRepository.
@@ -118,6 +157,26 @@ func (in *PolledRepositoryList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PolledRepositorySpec) DeepCopyInto(out *PolledRepositorySpec) {
 	*out = *in
+	if in.Refs != nil {
+		in, out := &in.Refs, &out.Refs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PullRequests != nil {
+		in, out := &in.PullRequests, &out.PullRequests
+		*out = new(PullRequestPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TagPolicy != nil {
+		in, out := &in.TagPolicy, &out.TagPolicy
+		*out = new(TagPolicy)
+		**out = **in
+	}
+	if in.Releases != nil {
+		in, out := &in.Releases, &out.Releases
+		*out = new(ReleasePolicy)
+		**out = **in
+	}
 	if in.Auth != nil {
 		in, out := &in.Auth, &out.Auth
 		*out = new(AuthSecret)
@@ -128,6 +187,28 @@ func (in *PolledRepositorySpec) DeepCopyInto(out *PolledRepositorySpec) {
 		*out = new(v1.Duration)
 		**out = **in
 	}
+	if in.MinFrequency != nil {
+		in, out := &in.MinFrequency, &out.MinFrequency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.MaxFrequency != nil {
+		in, out := &in.MaxFrequency, &out.MaxFrequency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = make([]PollSchedule, len(*in))
+		copy(*out, *in)
+	}
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]PollWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolledRepositorySpec.
@@ -143,7 +224,45 @@ func (in *PolledRepositorySpec) DeepCopy() *PolledRepositorySpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PolledRepositoryStatus) DeepCopyInto(out *PolledRepositoryStatus) {
 	*out = *in
-	out.PollStatus = in.PollStatus
+	in.PollStatus.DeepCopyInto(&out.PollStatus)
+	if in.Refs != nil {
+		in, out := &in.Refs, &out.Refs
+		*out = make(map[string]PollStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.PullRequests != nil {
+		in, out := &in.PullRequests, &out.PullRequests
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastPollTime != nil {
+		in, out := &in.LastPollTime, &out.LastPollTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastChangeTime != nil {
+		in, out := &in.LastChangeTime, &out.LastChangeTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NextPollTime != nil {
+		in, out := &in.NextPollTime, &out.NextPollTime
+		*out = (*in).DeepCopy()
+	}
+	if in.EffectiveFrequency != nil {
+		in, out := &in.EffectiveFrequency, &out.EffectiveFrequency
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolledRepositoryStatus.
@@ -155,3 +274,58 @@ func (in *PolledRepositoryStatus) DeepCopy() *PolledRepositoryStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PullRequestPolicy) DeepCopyInto(out *PullRequestPolicy) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetBranches != nil {
+		in, out := &in.TargetBranches, &out.TargetBranches
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PullRequestPolicy.
+func (in *PullRequestPolicy) DeepCopy() *PullRequestPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PullRequestPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleasePolicy) DeepCopyInto(out *ReleasePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleasePolicy.
+func (in *ReleasePolicy) DeepCopy() *ReleasePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleasePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TagPolicy) DeepCopyInto(out *TagPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TagPolicy.
+func (in *TagPolicy) DeepCopy() *TagPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TagPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
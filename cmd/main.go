@@ -19,8 +19,14 @@ package main
 import (
 	"crypto/tls"
 	"flag"
+	"fmt"
+	"maps"
 	"net/http"
 	"os"
+	"time"
+	// The distroless image has no time zone database, this is needed for the
+	// time zones in schedules and windows.
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -30,6 +36,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -62,6 +69,10 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var providerHosts string
+	var providerHostsFile string
+	var maxConcurrentReconciles int
+	var githubBatchWindow time.Duration
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -73,6 +84,15 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&providerHosts, "provider-hosts", "",
+		"Comma separated host=type pairs used to detect the type of repositories that have no type e.g. github.example.com=github")
+	flag.StringVar(&providerHostsFile, "provider-hosts-file", "",
+		"A file with host=type pairs, one per line, used to detect the type of repositories that have no type, this can be mounted from a ConfigMap.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 1,
+		"The number of repositories that can be polled concurrently.")
+	flag.DurationVar(&githubBatchWindow, "github-batch-window", 0,
+		"If set, polls for GitHub repositories with the same host and token that are made within this window are "+
+			"batched into a single GraphQL query, this is most effective with --max-concurrent-reconciles greater than 1.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -131,6 +151,9 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "b668fe04.gitops.tools",
+		Controller: config.Controller{
+			MaxConcurrentReconciles: maxConcurrentReconciles,
+		},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -148,15 +171,28 @@ func main() {
 		os.Exit(1)
 	}
 
+	knownHosts, err := loadProviderHosts(providerHosts, providerHostsFile)
+	if err != nil {
+		setupLog.Error(err, "unable to parse the provider hosts")
+		os.Exit(1)
+	}
+
+	pollerFactory := controller.MakeCommitPoller
+	if githubBatchWindow > 0 {
+		setupLog.Info("batching GitHub polls", "window", githubBatchWindow)
+		pollerFactory = controller.MakeBatchingCommitPoller(git.NewGitHubBatcher(githubBatchWindow))
+	}
+
 	if err = (&controller.PolledRepositoryReconciler{
 		Client:          mgr.GetClient(),
 		Scheme:          mgr.GetScheme(),
 		EventDispatcher: cloudevents.CloudEventDispatcher{},
 		SecretGetter:    secrets.New(mgr.GetClient()),
 		HTTPClient:      http.DefaultClient,
-		PollerFactory: func(cl *http.Client, repo *pollingv1alpha1.PolledRepository, endpoint, token string) git.CommitPoller {
-			return controller.MakeCommitPoller(cl, repo, endpoint, token)
-		},
+		TypeDetector:    git.NewProviderDetector(http.DefaultClient, knownHosts),
+		GitHubAppTokens: git.NewGitHubAppTokenSources(http.DefaultClient),
+		RateLimits:      controller.NewRateLimits(),
+		PollerFactory:   pollerFactory,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PolledRepository")
 		os.Exit(1)
@@ -178,3 +214,25 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// loadProviderHosts parses the provider hosts from the flag and the file, the
+// hosts in the file take precedence.
+func loadProviderHosts(hosts, filename string) (map[string]pollingv1alpha1.RepoType, error) {
+	parsed, err := git.ParseProviderHosts(hosts)
+	if err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		return parsed, nil
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read provider hosts: %w", err)
+	}
+	fromFile, err := git.ParseProviderHosts(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse provider hosts from %s: %w", filename, err)
+	}
+	maps.Copy(parsed, fromFile)
+	return parsed, nil
+}
@@ -18,13 +18,18 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -32,17 +37,24 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/pkg/cloudevents"
 	"github.com/gitops-tools/gitpoller-controller/pkg/git"
 	"github.com/gitops-tools/gitpoller-controller/pkg/secrets"
 )
 
-// EventDispatcher implementations publish the commit to the endpoint in the
+// EventDispatcher implementations publish the event to the endpoint in the
 // PolledRepository.
 type EventDispatcher interface {
-	Dispatch(ctx context.Context, repo pollingv1.PolledRepository, commit map[string]any) error
+	Dispatch(ctx context.Context, repo pollingv1.PolledRepository, event cloudevents.Event) error
 }
 
-type pollerFactoryFunc func(cl *http.Client, repo *pollingv1.PolledRepository, endpoint, authToken string) git.CommitPoller
+// RepoTypeDetector implementations detect the type of a repository from the
+// URL when no type is provided.
+type RepoTypeDetector interface {
+	Detect(ctx context.Context, repoURL string) (pollingv1.RepoType, error)
+}
+
+type pollerFactoryFunc func(cl *http.Client, repo *pollingv1.PolledRepository, endpoint string, creds git.Credentials) git.CommitPoller
 
 // PolledRepositoryReconciler reconciles a PolledRepository object
 type PolledRepositoryReconciler struct {
@@ -51,8 +63,16 @@ type PolledRepositoryReconciler struct {
 	Scheme        *runtime.Scheme
 	HTTPClient    *http.Client
 	PollerFactory pollerFactoryFunc
+	TypeDetector  RepoTypeDetector
+	// GitHubAppTokens caches the tokens for GitHub App installations.
+	GitHubAppTokens *git.GitHubAppTokenSources
+	// RateLimits delays polls when the rate limit for a host and credential
+	// is exhausted.
+	RateLimits *RateLimits
 	EventDispatcher
 	secrets.SecretGetter
+
+	now func() time.Time
 }
 
 // +kubebuilder:rbac:groups=polling.gitops.tools,resources=polledrepositories,verbs=get;list;watch;create;update;patch;delete
@@ -70,122 +90,747 @@ func (r *PolledRepositoryReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	repo := pollingv1.PolledRepository{}
 	err := r.Client.Get(ctx, req.NamespacedName, &repo)
 	if err != nil {
-		if errors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) {
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, fmt.Errorf("failed to load repository %s: %w", req, err)
 	}
+	// The status is patched with the changes from this copy.
+	base := repo.DeepCopy()
+
+	if repo.Spec.Suspend {
+		reqLogger.Info("polling is suspended")
+		repo.Status.ObservedGeneration = repo.Generation
+		repo.Status.NextPollTime = nil
+		setCondition(&repo, pollingv1.SuspendedCondition, metav1.ConditionTrue, pollingv1.SuspendedReason, "polling is suspended")
+		if err := r.patchStatus(ctx, &repo, base); err != nil {
+			reqLogger.Error(err, "unable to update Repository status")
+			return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+		}
+		// Resuming changes the generation, which triggers a reconcile.
+		return ctrl.Result{}, nil
+	}
+	// The Suspended condition is removed by the first successful poll, so
+	// that the changes while polling was suspended are only dispatched if
+	// requested, even if that poll is retried.
+	resumed := meta.IsStatusConditionTrue(repo.Status.Conditions, pollingv1.SuspendedCondition)
+	if resumed {
+		reqLogger.Info("polling resumed")
+	}
+
+	// Reconciles that aren't triggered by the requeue e.g. when the
+	// controller restarts, wait for the next poll unless the spec changed or
+	// a poll was requested.
+	requested := requestedAt(repo.GetAnnotations())
+	now := r.timeNow()
+	if next := repo.Status.NextPollTime; next != nil && next.After(now) && repo.Status.ObservedGeneration == repo.Generation && requested == repo.Status.LastHandledRequestedAt {
+		reqLogger.Info("next poll is not due, requeueing", "nextPollTime", next)
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
+
+	var delay time.Duration
+	schedule, err := newPollSchedule(repo.Spec)
+	switch {
+	case err != nil:
+		reqLogger.Error(err, "Parsing the schedule failed")
+		// Retrying won't help until the schedule is changed.
+		delay = r.pollFailed(&repo, frequencyFor(&repo), pollingv1.ConfigurationErrorReason, err, true)
+	case !schedule.allowed(now):
+		// The next poll is when the windows allow it.
+		reqLogger.Info("polling is not allowed by the windows")
+	default:
+		var polled bool
+		delay, polled, err = r.pollRepository(ctx, &repo, base, resumed, schedule, now)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		// A requested poll is handled when the poll is made after the rate
+		// limit resets.
+		if polled {
+			repo.Status.LastHandledRequestedAt = requested
+		}
+	}
+
+	next := now.Add(delay)
+	if schedule != nil {
+		// The schedule was checked for an allowed time when it was parsed.
+		next, _ = schedule.nextAllowed(next)
+		delay = next.Sub(now)
+	}
+	nextPollTime := metav1.NewTime(next)
+	repo.Status.NextPollTime = &nextPollTime
+	if err := r.patchStatus(ctx, &repo, base); err != nil {
+		reqLogger.Error(err, "unable to update Repository status")
+		return ctrl.Result{}, fmt.Errorf("failed to update status: %w", err)
+	}
+
+	reqLogger.Info("requeueing next check", "delay", delay)
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
+
+// pollRepository polls the repository and dispatches an event if the ref has
+// changed, and returns the delay until the next poll.
+//
+// If polling was resumed, the Suspended condition is removed when the poll
+// succeeds, and the change is only dispatched if DispatchOnResume is true.
+//
+// The delay is the time from now until the next scheduled poll, transient
+// failures are retried sooner, and false is returned if the poll was delayed
+// because the rate limit is exhausted.
+//
+// The outcome of the poll is recorded in the status, the returned errors are
+// only for failures to update the status.
+func (r *PolledRepositoryReconciler) pollRepository(ctx context.Context, repo, base *pollingv1.PolledRepository, resumed bool, schedule *pollSchedule, now time.Time) (time.Duration, bool, error) {
+	reqLogger := logr.FromContextOrDiscard(ctx)
+	interval := schedule.interval(now, frequencyFor(repo))
 
-	repoName, endpoint, err := repoFromURL(repo.Spec.URL)
+	repoType, err := r.repoTypeFor(ctx, repo, base)
+	if err != nil {
+		reqLogger.Error(err, "Detecting the repository type failed", "repoURL", repo.Spec.URL)
+		reason := pollingv1.ConfigurationErrorReason
+		if errors.Is(err, git.ErrServerError) {
+			reason = pollingv1.ServerErrorReason
+		}
+		return r.pollFailed(repo, interval, reason, err, false), true, nil
+	}
+
+	repoName, endpoint, err := repoFromURL(repoType, repo.Spec.URL)
 	if err != nil {
 		reqLogger.Error(err, "Parsing the repo from the URL failed", "repoURL", repo.Spec.URL)
-		return ctrl.Result{}, err
+		// Retrying won't help until the URL is changed.
+		return r.pollFailed(repo, interval, pollingv1.ConfigurationErrorReason, err, true), true, nil
+	}
+	if repo.Spec.APIURL != "" {
+		endpoint = repo.Spec.APIURL
 	}
 	repo.Status.PollStatus.Ref = repo.Spec.Ref
 
-	authToken, err := r.authTokenForRepo(ctx, reqLogger, req.Namespace, repo)
+	creds, err := r.credentialsForRepo(ctx, repo.Namespace, *repo, repoType, endpoint)
 	if err != nil {
-		// TODO: Patch this!
-		repo.Status.LastError = err.Error()
 		reqLogger.Error(err, "Getting the auth token failed")
-		if err := r.Client.Status().Update(ctx, &repo); err != nil {
-			reqLogger.Error(err, "unable to update Repository status")
+		// The secret may not have been created yet.
+		return r.pollFailed(repo, interval, pollingv1.ConfigurationErrorReason, err, false), true, nil
+	}
+
+	limitKey := rateLimitKeyFor(endpoint, repo.Namespace, repo.Spec.Auth)
+	if delay := r.RateLimits.Delay(limitKey); delay > 0 {
+		reqLogger.Info("rate limit exhausted, delaying poll", "host", limitKey.host, "credential", limitKey.credential, "delay", delay)
+		return delay, false, nil
+	}
+
+	poller := r.PollerFactory(r.RateLimits.Client(r.HTTPClient, limitKey), repo, endpoint, creds)
+	if poller == nil {
+		err := fmt.Errorf("unsupported repository type %q", repoType)
+		reqLogger.Error(err, "creating the poller failed")
+		// Retrying won't help until the type is changed.
+		return r.pollFailed(repo, interval, pollingv1.ConfigurationErrorReason, err, true), true, nil
+	}
+	if repo.Spec.TagPolicy != nil {
+		poller, err = tagPollerFor(poller, repoType, *repo.Spec.TagPolicy)
+		if err != nil {
+			reqLogger.Error(err, "creating the tag poller failed")
+			// Retrying won't help until the tag policy is changed.
+			return r.pollFailed(repo, interval, pollingv1.ConfigurationErrorReason, err, true), true, nil
+		}
+	}
+	if repo.Spec.Releases != nil {
+		poller, err = releasePollerFor(poller, repoType, *repo.Spec.Releases)
+		if err != nil {
+			reqLogger.Error(err, "creating the release poller failed")
+			// Retrying won't help until the type is changed.
+			return r.pollFailed(repo, interval, pollingv1.ConfigurationErrorReason, err, true), true, nil
 		}
-		// TODO: improve the error
-		return ctrl.Result{}, err
 	}
 
-	// TODO: handle pollerFactory returning nil/error
-	newStatus, commit, err := r.PollerFactory(r.HTTPClient, &repo, endpoint, authToken).Poll(ctx, repoName, repo.Status.PollStatus)
-	if err != nil {
-		// TODO: Patch this!
-		repo.Status.LastError = err.Error()
-		reqLogger.Error(err, "repository poll failed")
-		if err := r.Client.Status().Update(ctx, &repo); err != nil {
-			reqLogger.Error(err, "unable to update Repository status")
+	var patterns []refPattern
+	var branches git.BranchLister
+	if len(repo.Spec.Refs) > 0 {
+		patterns, branches, err = refPollerFor(poller, repoType, repo.Spec.Refs)
+		if err != nil {
+			reqLogger.Error(err, "creating the refs poller failed")
+			// Retrying won't help until the refs are changed.
+			return r.pollFailed(repo, interval, pollingv1.ConfigurationErrorReason, err, true), true, nil
+		}
+	}
+
+	var filter pullRequestFilter
+	var pullRequests git.PullRequestLister
+	if repo.Spec.PullRequests != nil {
+		filter, pullRequests, err = pullRequestPollerFor(poller, repoType, *repo.Spec.PullRequests)
+		if err != nil {
+			reqLogger.Error(err, "creating the pull requests poller failed")
+			// Retrying won't help until the pull requests policy is changed.
+			return r.pollFailed(repo, interval, pollingv1.ConfigurationErrorReason, err, true), true, nil
 		}
-		// TODO: improve the error
-		return ctrl.Result{}, err
 	}
 
-	reqLogger.Info("polled", "status", newStatus)
+	var result pollResult
+	switch {
+	case patterns != nil:
+		result, err = pollRefs(ctx, branches, poller, repoName, patterns, repo.Status.Refs)
+	case pullRequests != nil:
+		result, err = pollPullRequests(ctx, pullRequests, repoName, filter, repo.Status.PullRequests)
+	default:
+		eventType := cloudevents.CommitEvent
+		if repo.Spec.Releases != nil {
+			eventType = cloudevents.ReleaseEvent
+		}
+		result, err = pollRef(ctx, poller, repoName, repo.Status.PollStatus, eventType)
+	}
+	if err != nil {
+		reason, stalled := pollErrorReason(err)
+		reqLogger.Error(err, "repository poll failed", "reason", reason)
+		delay := r.pollFailed(repo, interval, reason, err, stalled)
 
+		var rateLimited *git.RateLimitError
+		if errors.As(err, &rateLimited) {
+			// Other repositories with the same host and credential are
+			// delayed until the reset.
+			r.RateLimits.Record(limitKey, rateLimited.RateLimit)
+			delay = rateLimited.Reset.Sub(r.timeNow())
+		}
+		return delay, true, nil
+	}
+
+	pollTime := metav1.NewTime(r.timeNow())
 	repo.Status.LastError = ""
-	changed := !newStatus.Equal(repo.Status.PollStatus)
-	if repo.Status.LastError != "" {
-		repo.Status.LastError = ""
-		changed = true
+	repo.Status.LastPollTime = &pollTime
+	markReady(repo, result.message)
+	if resumed {
+		meta.RemoveStatusCondition(&repo.Status.Conditions, pollingv1.SuspendedCondition)
 	}
-	if !changed {
-		reqLogger.Info("poll status unchanged, requeueing next check", "frequency", repo.Spec.Frequency)
-		return ctrl.Result{RequeueAfter: repo.Spec.Frequency.Duration}, nil
+	adaptFrequency(repo, result.changed)
+	interval = schedule.interval(now, frequencyFor(repo))
+	if !result.changed {
+		reqLogger.Info("poll status unchanged")
+		if patterns == nil && pullRequests == nil {
+			// The ETag can change without a change to dispatch.
+			repo.Status.PollStatus = result.status
+		}
+		return interval, true, nil
 	}
 
-	reqLogger.Info("poll status changed", "status", newStatus)
-	repo.Status.PollStatus = newStatus
-	if err := r.Client.Status().Update(ctx, &repo); err != nil {
+	switch {
+	case patterns != nil:
+		reqLogger.Info("refs changed", "refs", result.refs)
+		repo.Status.Refs = result.refs
+	case pullRequests != nil:
+		reqLogger.Info("pull requests changed", "pullRequests", result.pullRequests)
+		repo.Status.PullRequests = result.pullRequests
+	default:
+		reqLogger.Info("poll status changed", "status", result.status)
+		repo.Status.PollStatus = result.status
+	}
+	repo.Status.LastChangeTime = &pollTime
+	if err := r.patchStatus(ctx, repo, base); err != nil {
 		reqLogger.Error(err, "unable to update Repository status")
-		return ctrl.Result{}, fmt.Errorf("failed to update status after change detected: %w", err)
+		return 0, true, fmt.Errorf("failed to update status after change detected: %w", err)
+	}
+
+	if resumed && !repo.Spec.DispatchOnResume {
+		reqLogger.Info("not dispatching the change made while polling was suspended")
+		return interval, true, nil
+	}
+	r.dispatchEvents(ctx, repo, result.events, result.delivered)
+
+	return interval, true, nil
+}
+
+// pollResult is the outcome of a successful poll.
+type pollResult struct {
+	// status is the polled status of the Ref.
+	status pollingv1.PollStatus
+	// refs is the polled status of each branch that matches the Refs.
+	refs map[string]pollingv1.PollStatus
+	// pullRequests is the head SHA of each pull request that matches the
+	// PullRequests policy.
+	pullRequests map[string]string
+	changed      bool
+	events       []cloudevents.Event
+	// message is the message for the Ready condition.
+	message string
+	// delivered describes the events for the EventDelivered condition.
+	delivered string
+}
+
+// pollRef polls the Ref, or the tag selected by the TagPolicy, or the release
+// selected by the Releases policy.
+func pollRef(ctx context.Context, poller git.CommitPoller, repoName string, last pollingv1.PollStatus, eventType string) (pollResult, error) {
+	newStatus, commit, err := poller.Poll(ctx, repoName, last)
+	if err != nil {
+		return pollResult{}, err
+	}
+	logr.FromContextOrDiscard(ctx).Info("polled", "status", newStatus)
+
+	ref, message, delivered := newStatus.Ref, fmt.Sprintf("polled ref %q at %s", newStatus.Ref, newStatus.SHA), "the event for "+newStatus.SHA
+	switch {
+	case newStatus.Tag != "" && newStatus.SHA == "":
+		// GitHub releases don't have the SHA of the released commit.
+		ref, message, delivered = newStatus.Tag, fmt.Sprintf("selected tag %q", newStatus.Tag), fmt.Sprintf("the event for tag %q", newStatus.Tag)
+	case newStatus.Tag != "":
+		ref, message = newStatus.Tag, fmt.Sprintf("selected tag %q at %s", newStatus.Tag, newStatus.SHA)
+	}
+	// A new ETag on its own is saved without dispatching an event.
+	changed := newStatus.Ref != last.Ref || newStatus.SHA != last.SHA || newStatus.Tag != last.Tag
+	return pollResult{
+		status:    newStatus,
+		changed:   changed,
+		events:    []cloudevents.Event{{Type: eventType, Ref: ref, Data: commit}},
+		message:   message,
+		delivered: delivered,
+	}, nil
+}
+
+// dispatchEvents dispatches the events in order, and records the outcome in
+// the EventDelivered condition, dispatching stops at the first failure.
+func (r *PolledRepositoryReconciler) dispatchEvents(ctx context.Context, repo *pollingv1.PolledRepository, events []cloudevents.Event, delivered string) {
+	if len(events) == 0 {
+		return
+	}
+	reqLogger := logr.FromContextOrDiscard(ctx)
+	for _, event := range events {
+		if err := r.EventDispatcher.Dispatch(ctx, *repo, event); err != nil {
+			reqLogger.Error(err, "failed to dispatch event", "endpoint", repo.Spec.Endpoint, "ref", event.Ref)
+			setCondition(repo, pollingv1.EventDeliveredCondition, metav1.ConditionFalse, pollingv1.DeliveryFailedReason, err.Error())
+			return
+		}
+	}
+	setCondition(repo, pollingv1.EventDeliveredCondition, metav1.ConditionTrue, pollingv1.DeliveredReason, fmt.Sprintf("delivered %s to %s", delivered, repo.Spec.Endpoint))
+}
+
+// tagPollerFor wraps the poller to select a tag with the policy, the poller
+// must be able to list the tags.
+func tagPollerFor(poller git.CommitPoller, repoType pollingv1.RepoType, policy pollingv1.TagPolicy) (git.CommitPoller, error) {
+	lister, ok := poller.(git.TagLister)
+	if !ok {
+		return nil, fmt.Errorf("tag policies are not supported for the %q type", repoType)
 	}
+	return git.NewTagPoller(lister, policy)
+}
 
-	if err := r.EventDispatcher.Dispatch(ctx, repo, commit); err != nil {
-		reqLogger.Error(err, "failed to dispatch commit")
-		return ctrl.Result{}, fmt.Errorf("failed to send notification to %q: %w", repo.Spec.Endpoint, err)
+// releasePollerFor wraps the poller to select a release with the policy, the
+// poller must be able to list the releases.
+func releasePollerFor(poller git.CommitPoller, repoType pollingv1.RepoType, policy pollingv1.ReleasePolicy) (git.CommitPoller, error) {
+	lister, ok := poller.(git.ReleaseLister)
+	if !ok {
+		return nil, fmt.Errorf("releases are not supported for the %q type", repoType)
 	}
+	return git.NewReleasePoller(lister, policy), nil
+}
 
-	reqLogger.Info("requeueing next check", "frequency", repo.Spec.Frequency.Duration)
-	return ctrl.Result{RequeueAfter: repo.Spec.Frequency.Duration}, nil
+// minRetryDelay is the delay before the first retry after a transient failure.
+const minRetryDelay = 10 * time.Second
+
+// pollFailed records the failure in the status and returns the delay until
+// the next poll.
+//
+// If the repository is stalled, the next poll is after the interval, otherwise
+// the poll is retried sooner.
+func (r *PolledRepositoryReconciler) pollFailed(repo *pollingv1.PolledRepository, interval time.Duration, reason string, err error, stalled bool) time.Duration {
+	delay := interval
+	if !stalled {
+		delay = r.retryDelay(repo, interval)
+	}
+	repo.Status.LastError = err.Error()
+	markNotReady(repo, reason, err.Error(), stalled)
+	return delay
+}
+
+// retryDelay returns the delay before retrying after a transient failure.
+//
+// The delay is the time since the repository stopped being Ready, which
+// doubles the delay with each retry, it's at least minRetryDelay and at most
+// the interval.
+func (r *PolledRepositoryReconciler) retryDelay(repo *pollingv1.PolledRepository, interval time.Duration) time.Duration {
+	delay := minRetryDelay
+	if c := meta.FindStatusCondition(repo.Status.Conditions, pollingv1.ReadyCondition); c != nil && c.Status == metav1.ConditionFalse {
+		delay = max(delay, r.timeNow().Sub(c.LastTransitionTime.Time))
+	}
+	return min(delay, interval)
+}
+
+// pollErrorReasons maps the kinds of poll error to the reason for the Ready
+// condition, and whether or not the repository is stalled until the
+// configuration is fixed, other errors are retried sooner.
+var pollErrorReasons = []struct {
+	kind    error
+	reason  string
+	stalled bool
+}{
+	// Rate limits are retried when the limit resets.
+	{git.ErrRateLimited, pollingv1.RateLimitedReason, false},
+	{git.ErrUnauthorized, pollingv1.UnauthorizedReason, true},
+	{git.ErrForbidden, pollingv1.ForbiddenReason, true},
+	{git.ErrNotFound, pollingv1.NotFoundReason, true},
+	{git.ErrMalformedResponse, pollingv1.MalformedResponseReason, true},
+	{git.ErrServerError, pollingv1.ServerErrorReason, false},
+	{git.ErrTimeout, pollingv1.TimeoutReason, false},
+}
+
+func pollErrorReason(err error) (string, bool) {
+	for _, r := range pollErrorReasons {
+		if errors.Is(err, r.kind) {
+			return r.reason, r.stalled
+		}
+	}
+	return pollingv1.PollFailedReason, false
+}
+
+// markReady sets the Ready condition to True and removes the Reconciling and
+// Stalled conditions.
+//
+// The ObservedGeneration is updated so that kstatus considers the status to
+// be current.
+func markReady(repo *pollingv1.PolledRepository, message string) {
+	repo.Status.ObservedGeneration = repo.Generation
+	setCondition(repo, pollingv1.ReadyCondition, metav1.ConditionTrue, pollingv1.SucceededReason, message)
+	meta.RemoveStatusCondition(&repo.Status.Conditions, pollingv1.ReconcilingCondition)
+	meta.RemoveStatusCondition(&repo.Status.Conditions, pollingv1.StalledCondition)
+}
+
+// markNotReady sets the Ready condition to False.
+//
+// If the repository is stalled, the Stalled condition is set to True,
+// otherwise the poll will be retried and Reconciling is set to True.
+func markNotReady(repo *pollingv1.PolledRepository, reason, message string, stalled bool) {
+	repo.Status.ObservedGeneration = repo.Generation
+	setCondition(repo, pollingv1.ReadyCondition, metav1.ConditionFalse, reason, message)
+	if stalled {
+		setCondition(repo, pollingv1.StalledCondition, metav1.ConditionTrue, reason, message)
+		meta.RemoveStatusCondition(&repo.Status.Conditions, pollingv1.ReconcilingCondition)
+		return
+	}
+	setCondition(repo, pollingv1.ReconcilingCondition, metav1.ConditionTrue, reason, message)
+	meta.RemoveStatusCondition(&repo.Status.Conditions, pollingv1.StalledCondition)
+}
+
+func setCondition(repo *pollingv1.PolledRepository, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&repo.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: repo.Generation,
+	})
+}
+
+// patchStatus patches the status with the changes since the base, and updates
+// the base to match.
+//
+// A merge patch doesn't include the resourceVersion, so unlike an update, it
+// doesn't fail when something else has modified the repository since it was
+// loaded. Only the fields that changed are sent, other fields in the status
+// are left as they are.
+func (r *PolledRepositoryReconciler) patchStatus(ctx context.Context, repo, base *pollingv1.PolledRepository) error {
+	if err := r.Client.Status().Patch(ctx, repo, client.MergeFrom(base)); err != nil {
+		return err
+	}
+	repo.DeepCopyInto(base)
+	return nil
+}
+
+func (r *PolledRepositoryReconciler) timeNow() time.Time {
+	if r.now == nil {
+		return time.Now()
+	}
+	return r.now()
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PolledRepositoryReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&pollingv1.PolledRepository{}).
-		WithEventFilter(predicate.GenerationChangedPredicate{}).
+		WithEventFilter(predicate.Or(predicate.GenerationChangedPredicate{}, requestedAtChangedPredicate{})).
 		Complete(r)
 }
 
-func (r *PolledRepositoryReconciler) authTokenForRepo(ctx context.Context, logger logr.Logger, namespace string, repo pollingv1.PolledRepository) (string, error) {
+// repoTypeFor returns the type of the repository, if no type is provided, the
+// type is detected and recorded in the status.
+func (r *PolledRepositoryReconciler) repoTypeFor(ctx context.Context, repo, base *pollingv1.PolledRepository) (pollingv1.RepoType, error) {
+	if repo.Spec.Type != "" {
+		repo.Status.DetectedType = ""
+		return repo.Spec.Type, nil
+	}
+
+	var detected pollingv1.RepoType
+	switch {
+	case isSSHURL(repo.Spec.URL):
+		detected = pollingv1.Git
+	case r.TypeDetector == nil:
+		return "", fmt.Errorf("no type provided for %s", repo.Spec.URL)
+	default:
+		var err error
+		detected, err = r.TypeDetector.Detect(ctx, repo.Spec.URL)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if detected != repo.Status.DetectedType {
+		logr.FromContextOrDiscard(ctx).Info("detected repository type", "type", detected)
+		repo.Status.DetectedType = detected
+		if err := r.patchStatus(ctx, repo, base); err != nil {
+			return "", fmt.Errorf("failed to update status after detecting the type: %w", err)
+		}
+	}
+
+	return detected, nil
+}
+
+func (r *PolledRepositoryReconciler) credentialsForRepo(ctx context.Context, namespace string, repo pollingv1.PolledRepository, repoType pollingv1.RepoType, endpoint string) (git.Credentials, error) {
 	if repo.Spec.Auth == nil {
-		return "", nil
+		return git.Credentials{}, nil
+	}
+	secretName := types.NamespacedName{Name: repo.Spec.Auth.SecretRef.Name, Namespace: namespace}
+	if isSSHURL(repo.Spec.URL) {
+		return r.sshCredentialsForRepo(ctx, secretName, repo.Spec.Auth)
+	}
+	if repo.Spec.Auth.GitHubApp {
+		if repoType != pollingv1.GitHub {
+			return git.Credentials{}, fmt.Errorf("GitHub App authentication is not supported for the %s type", repoType)
+		}
+		return r.gitHubAppCredentialsForRepo(ctx, secretName, endpoint)
 	}
 	key := "token"
 	if repo.Spec.Auth.Key != "" {
 		key = repo.Spec.Auth.Key
 	}
-	authToken, err := r.SecretGetter.SecretToken(ctx, types.NamespacedName{Name: repo.Spec.Auth.SecretRef.Name, Namespace: namespace}, key)
+	authToken, err := r.SecretGetter.SecretToken(ctx, secretName, key)
+	if err != nil {
+		return git.Credentials{}, err
+	}
+
+	var username string
+	if repo.Spec.Auth.UsernameKey != "" {
+		username, err = r.SecretGetter.SecretToken(ctx, secretName, repo.Spec.Auth.UsernameKey)
+		if err != nil {
+			return git.Credentials{}, err
+		}
+	}
+
+	return git.Credentials{Username: username, Token: authToken}, nil
+}
+
+func (r *PolledRepositoryReconciler) sshCredentialsForRepo(ctx context.Context, secretName types.NamespacedName, auth *pollingv1.AuthSecret) (git.Credentials, error) {
+	identityKey := "identity"
+	if auth.IdentityKey != "" {
+		identityKey = auth.IdentityKey
+	}
+	knownHostsKey := "known_hosts"
+	if auth.KnownHostsKey != "" {
+		knownHostsKey = auth.KnownHostsKey
+	}
+	privateKey, err := r.SecretGetter.SecretToken(ctx, secretName, identityKey)
+	if err != nil {
+		return git.Credentials{}, err
+	}
+	knownHosts, err := r.SecretGetter.SecretToken(ctx, secretName, knownHostsKey)
+	if err != nil {
+		return git.Credentials{}, err
+	}
+
+	return git.Credentials{PrivateKey: []byte(privateKey), KnownHosts: []byte(knownHosts)}, nil
+}
+
+// gitHubAppCredentialsForRepo returns credentials with a TokenSource for the
+// GitHub App installation, the tokens are shared by all repositories that use
+// the same App installation.
+func (r *PolledRepositoryReconciler) gitHubAppCredentialsForRepo(ctx context.Context, secretName types.NamespacedName, endpoint string) (git.Credentials, error) {
+	if r.GitHubAppTokens == nil {
+		return git.Credentials{}, fmt.Errorf("GitHub App authentication is not configured")
+	}
+	values := map[string]string{}
+	for _, key := range []string{"githubAppID", "githubAppInstallationID", "githubAppPrivateKey"} {
+		value, err := r.SecretGetter.SecretToken(ctx, secretName, key)
+		if err != nil {
+			return git.Credentials{}, err
+		}
+		values[key] = value
+	}
+	tokens, err := r.GitHubAppTokens.TokenSource(endpoint, values["githubAppID"], values["githubAppInstallationID"], []byte(values["githubAppPrivateKey"]))
 	if err != nil {
-		return "", err
+		return git.Credentials{}, err
 	}
 
-	return authToken, nil
+	return git.Credentials{TokenSource: tokens}, nil
 }
 
-func repoFromURL(s string) (string, string, error) {
+func repoFromURL(repoType pollingv1.RepoType, s string) (string, string, error) {
+	if isSSHURL(s) {
+		if repoType != pollingv1.Git {
+			return "", "", fmt.Errorf("failed to parse repo from URL %s: SSH URLs are only supported for the %s type", s, pollingv1.Git)
+		}
+		return sshRepoFromURL(s)
+	}
 	parsed, err := url.Parse(s)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to parse repo from URL %s: %s", s, err)
 	}
+	// The Git protocol uses the clone URL as-is, there's no separate API host.
+	if repoType == pollingv1.Git {
+		return strings.TrimPrefix(parsed.Path, "/"), fmt.Sprintf("%s://%s", parsed.Scheme, parsed.Host), nil
+	}
 	host := parsed.Host
-	if strings.HasSuffix(host, "github.com") {
-		host = "api." + host
+	if host == "bitbucket.org" {
+		host = "api.bitbucket.org"
 	}
 
 	// TODO: This should create a new URL with scheme and host?
 	endpoint := fmt.Sprintf("%s://%s", parsed.Scheme, host)
+	repoPath := strings.TrimPrefix(strings.TrimSuffix(parsed.Path, ".git"), "/")
+
+	switch repoType {
+	case pollingv1.GitHub:
+		endpoint, err := git.GitHubAPIURL(s)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse repo from URL %s: %s", s, err)
+		}
+		return repoPath, endpoint, nil
+	case pollingv1.BitbucketServer:
+		contextPath, repoName, err := bitbucketServerRepoFromPath(repoPath)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse repo from URL %s: %s", s, err)
+		}
+		return repoName, strings.TrimSuffix(endpoint+"/"+contextPath, "/"), nil
+	case pollingv1.AzureDevOps:
+		repoName, err := azureDevOpsRepoFromPath(repoPath)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to parse repo from URL %s: %s", s, err)
+		}
+		return repoName, endpoint, nil
+	case pollingv1.Gerrit:
+		return gerritRepoFromPath(repoPath), endpoint, nil
+	}
+
+	return repoPath, endpoint, nil
+}
+
+// scpURLRE matches scp-style URLs e.g. git@github.com:org/repo.git
+var scpURLRE = regexp.MustCompile(`^([A-Za-z0-9._-]+)@([A-Za-z0-9.-]+):(.+)$`)
+
+func isSSHURL(s string) bool {
+	return strings.HasPrefix(s, "ssh://") || (!strings.Contains(s, "://") && scpURLRE.MatchString(s))
+}
+
+// sshRepoFromURL parses ssh:// and scp-style URLs into the path to pass to
+// git-upload-pack and an ssh://user@host:port endpoint.
+//
+// The path in scp-style URLs is relative to the user's home directory.
+func sshRepoFromURL(s string) (string, string, error) {
+	if m := scpURLRE.FindStringSubmatch(s); m != nil && !strings.HasPrefix(s, "ssh://") {
+		return m[3], fmt.Sprintf("ssh://%s@%s", m[1], m[2]), nil
+	}
+	parsed, err := url.Parse(s)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse repo from URL %s: %s", s, err)
+	}
+	endpoint := url.URL{Scheme: parsed.Scheme, Host: parsed.Host}
+	if parsed.User != nil {
+		endpoint.User = url.User(parsed.User.Username())
+	}
+	repoPath := parsed.Path
+	// ssh://host/~user/repo.git is relative to the user's home directory.
+	if strings.HasPrefix(repoPath, "/~") {
+		repoPath = strings.TrimPrefix(repoPath, "/")
+	}
+	return repoPath, endpoint.String(), nil
+}
 
-	return strings.TrimPrefix(strings.TrimSuffix(parsed.Path, ".git"), "/"), endpoint, nil
+// gerritRepoFromPath parses the project name from the clone path, which can be
+// the authenticated path "a/project" or the admin UI path "admin/repos/project".
+func gerritRepoFromPath(s string) string {
+	if project, ok := strings.CutPrefix(s, "a/"); ok {
+		return project
+	}
+	if project, ok := strings.CutPrefix(s, "admin/repos/"); ok {
+		return project
+	}
+	return s
+}
+
+// bitbucketServerRepoFromPath parses the clone path "scm/PROJECT/repo" or the
+// browse path "projects/PROJECT/repos/repo/browse" into "PROJECT/repo".
+//
+// Bitbucket Server can be deployed with a context path e.g. /bitbucket so any
+// prefix before the "scm" or "projects" element is returned.
+func bitbucketServerRepoFromPath(s string) (string, string, error) {
+	elements := strings.Split(s, "/")
+	for i, element := range elements {
+		switch {
+		case element == "scm" && len(elements) >= i+3:
+			return strings.Join(elements[:i], "/"), strings.Join(elements[i+1:i+3], "/"), nil
+		case element == "projects" && len(elements) >= i+4 && elements[i+2] == "repos":
+			return strings.Join(elements[:i], "/"), elements[i+1] + "/" + elements[i+3], nil
+		}
+	}
+	return "", "", fmt.Errorf("unable to find the project and repository in path %q", s)
+}
+
+// azureDevOpsRepoFromPath parses the path "organization/project/_git/repo"
+// into "organization/project/repo".
+//
+// For "organization.visualstudio.com" and Azure DevOps Server hosts,
+// everything before the "_git" element is the path to the project.
+func azureDevOpsRepoFromPath(s string) (string, error) {
+	project, repo, ok := strings.Cut(s, "/_git/")
+	if !ok || project == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", fmt.Errorf("unable to find the project and repository in path %q", s)
+	}
+	return project + "/" + repo, nil
+}
+
+// MakeBatchingCommitPoller returns a poller factory that polls GitHub
+// repositories through the batcher, other types use MakeCommitPoller.
+func MakeBatchingCommitPoller(b *git.GitHubBatcher) func(cl *http.Client, repo *pollingv1.PolledRepository, endpoint string, creds git.Credentials) git.CommitPoller {
+	return func(cl *http.Client, repo *pollingv1.PolledRepository, endpoint string, creds git.Credentials) git.CommitPoller {
+		repoType := repo.Spec.Type
+		if repoType == "" {
+			repoType = repo.Status.DetectedType
+		}
+		// Tags, branches, pull requests and releases are listed with separate
+		// requests for each repository.
+		if repoType != pollingv1.GitHub || repo.Spec.TagPolicy != nil || len(repo.Spec.Refs) > 0 || repo.Spec.PullRequests != nil || repo.Spec.Releases != nil {
+			return MakeCommitPoller(cl, repo, endpoint, creds)
+		}
+		tokens := creds.TokenSource
+		if tokens == nil {
+			tokens = git.StaticToken(creds.Token)
+		}
+		return b.Poller(cl, endpoint, tokens)
+	}
 }
 
 // MakeCommitPoller creates the correct poller from the repository with
 // authentication.
 // TODO: allow custom TLS
-func MakeCommitPoller(cl *http.Client, repo *pollingv1.PolledRepository, endpoint, authToken string) git.CommitPoller {
-	switch repo.Spec.Type {
+func MakeCommitPoller(cl *http.Client, repo *pollingv1.PolledRepository, endpoint string, creds git.Credentials) git.CommitPoller {
+	repoType := repo.Spec.Type
+	if repoType == "" {
+		repoType = repo.Status.DetectedType
+	}
+	switch repoType {
 	case pollingv1.GitHub:
-		return git.NewGitHubPoller(cl, endpoint, authToken)
+		if creds.TokenSource != nil {
+			return git.NewGitHubPollerWithTokenSource(cl, endpoint, creds.TokenSource)
+		}
+		return git.NewGitHubPoller(cl, endpoint, creds.Token)
 	case pollingv1.GitLab:
-		return git.NewGitLabPoller(cl, endpoint, authToken)
+		return git.NewGitLabPoller(cl, endpoint, creds.Token)
+	case pollingv1.Gitea:
+		return git.NewGiteaPoller(cl, endpoint, creds.Token)
+	case pollingv1.Bitbucket:
+		return git.NewBitbucketPoller(cl, endpoint, creds.Username, creds.Token)
+	case pollingv1.BitbucketServer:
+		return git.NewBitbucketServerPoller(cl, endpoint, creds.Username, creds.Token)
+	case pollingv1.AzureDevOps:
+		return git.NewAzureDevOpsPoller(cl, endpoint, creds.Username, creds.Token)
+	case pollingv1.Gerrit:
+		return git.NewGerritPoller(cl, endpoint, creds.Username, creds.Token)
+	case pollingv1.Git:
+		if strings.HasPrefix(endpoint, "ssh://") {
+			return git.NewSSHPoller(endpoint, creds.PrivateKey, creds.KnownHosts)
+		}
+		return git.NewSmartHTTPPoller(cl, endpoint, creds.Username, creds.Token)
 	}
 	return nil
 }
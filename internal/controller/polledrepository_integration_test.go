@@ -85,8 +85,8 @@ func TestPolledRepositoryController(t *testing.T) {
 		Client:     k8sClient,
 		Scheme:     scheme,
 		HTTPClient: http.DefaultClient,
-		PollerFactory: func(cl *http.Client, repo *pollingv1.PolledRepository, endpoint, token string) git.CommitPoller {
-			return MakeCommitPoller(cl, repo, endpoint, token)
+		PollerFactory: func(cl *http.Client, repo *pollingv1.PolledRepository, endpoint string, creds git.Credentials) git.CommitPoller {
+			return MakeCommitPoller(cl, repo, endpoint, creds)
 		},
 		EventDispatcher: cloudevents.CloudEventDispatcher{},
 	}).SetupWithManager(k8sManager); err != nil {
@@ -0,0 +1,41 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+)
+
+// requestedAtChangedPredicate triggers a reconcile when the
+// RequestedAtAnnotation changes, these updates don't change the generation.
+type requestedAtChangedPredicate struct {
+	predicate.Funcs
+}
+
+func (requestedAtChangedPredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return false
+	}
+	return requestedAt(e.ObjectOld.GetAnnotations()) != requestedAt(e.ObjectNew.GetAnnotations())
+}
+
+func requestedAt(annotations map[string]string) string {
+	return annotations[pollingv1.RequestedAtAnnotation]
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+)
+
+func TestRequestedAtChangedPredicate(t *testing.T) {
+	predicateTests := []struct {
+		name string
+		old  map[string]string
+		new  map[string]string
+		want bool
+	}{
+		{"no annotation", nil, nil, false},
+		{"annotation added", nil, map[string]string{pollingv1.RequestedAtAnnotation: "1710234000"}, true},
+		{"annotation changed", map[string]string{pollingv1.RequestedAtAnnotation: "1710234000"}, map[string]string{pollingv1.RequestedAtAnnotation: "1710234060"}, true},
+		{"annotation unchanged", map[string]string{pollingv1.RequestedAtAnnotation: "1710234000"}, map[string]string{pollingv1.RequestedAtAnnotation: "1710234000"}, false},
+		{"other annotation changed", map[string]string{"example.com/owner": "a"}, map[string]string{"example.com/owner": "b"}, false},
+	}
+
+	for _, tt := range predicateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldRepo := newPolledRepository()
+			oldRepo.Annotations = tt.old
+			newRepo := newPolledRepository()
+			newRepo.Annotations = tt.new
+
+			if got := (requestedAtChangedPredicate{}).Update(event.UpdateEvent{ObjectOld: oldRepo, ObjectNew: newRepo}); got != tt.want {
+				t.Errorf("Update() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/go-logr/logr"
+
+	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/pkg/cloudevents"
+	"github.com/gitops-tools/gitpoller-controller/pkg/git"
+)
+
+// pullRequestFilter selects the pull requests that are tracked.
+type pullRequestFilter struct {
+	labels         []string
+	targetBranches []refPattern
+}
+
+// matches returns true if the pull request has all the labels and targets
+// one of the target branches.
+func (f pullRequestFilter) matches(pr git.PullRequest) bool {
+	for _, label := range f.labels {
+		if !slices.Contains(pr.Labels, label) {
+			return false
+		}
+	}
+	return len(f.targetBranches) == 0 || matchesAny(f.targetBranches, pr.BaseRef)
+}
+
+// pullRequestPollerFor parses the policy, the poller must be able to list the
+// pull requests.
+func pullRequestPollerFor(poller git.CommitPoller, repoType pollingv1.RepoType, policy pollingv1.PullRequestPolicy) (pullRequestFilter, git.PullRequestLister, error) {
+	lister, ok := poller.(git.PullRequestLister)
+	if !ok {
+		return pullRequestFilter{}, nil, fmt.Errorf("pull requests are not supported for the %q type", repoType)
+	}
+	targetBranches, err := parseRefPatterns(policy.TargetBranches)
+	if err != nil {
+		return pullRequestFilter{}, nil, err
+	}
+	return pullRequestFilter{labels: policy.Labels, targetBranches: targetBranches}, lister, nil
+}
+
+// pollPullRequests lists the open pull requests, and compares the head SHA of
+// each pull request that matches the filter with the last poll.
+//
+// Pull requests that match for the first time have a pull_request.opened
+// event, and pull requests with a new head SHA have a pull_request.updated
+// event.
+//
+// Pull requests that are no longer listed are fetched to find out whether
+// they were merged or closed. Pull requests that are still open and match the
+// filter are kept e.g. GitLab merge requests aren't listed while they're
+// locked during the merge, and pull requests that no longer match the filter
+// are removed from the status without an event.
+//
+// Pull requests that can't be found e.g. because they were deleted, have a
+// pull_request.closed event with only the number and the last head SHA.
+func pollPullRequests(ctx context.Context, lister git.PullRequestLister, repoName string, filter pullRequestFilter, last map[string]string) (pollResult, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+	pullRequests, err := lister.ListPullRequests(ctx, repoName)
+	if err != nil {
+		return pollResult{}, err
+	}
+
+	current := map[string]string{}
+	var events []cloudevents.Event
+	for _, pr := range pullRequests {
+		if !filter.matches(pr) {
+			continue
+		}
+		number := strconv.Itoa(pr.Number)
+		current[number] = pr.HeadSHA
+		previousSHA, ok := last[number]
+		switch {
+		case !ok:
+			events = append(events, pullRequestEvent(cloudevents.PullRequestOpenedEvent, pr))
+		case previousSHA != pr.HeadSHA:
+			event := pullRequestEvent(cloudevents.PullRequestUpdatedEvent, pr)
+			event.Data["previousHeadSHA"] = previousSHA
+			events = append(events, event)
+		}
+	}
+
+	for _, number := range slices.Sorted(maps.Keys(last)) {
+		if _, ok := current[number]; ok {
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			logger.Info("ignoring invalid pull request number", "number", number)
+			continue
+		}
+		pr, err := lister.GetPullRequest(ctx, repoName, n)
+		if errors.Is(err, git.ErrNotFound) {
+			logger.Info("pull request not found", "number", n)
+			pr = git.PullRequest{Number: n, State: git.PullRequestClosed, HeadSHA: last[number]}
+			events = append(events, pullRequestEvent(cloudevents.PullRequestClosedEvent, pr))
+			continue
+		}
+		if err != nil {
+			return pollResult{}, fmt.Errorf("failed to get pull request %d: %w", n, err)
+		}
+		switch pr.State {
+		case git.PullRequestMerged:
+			events = append(events, pullRequestEvent(cloudevents.PullRequestMergedEvent, pr))
+		case git.PullRequestClosed:
+			events = append(events, pullRequestEvent(cloudevents.PullRequestClosedEvent, pr))
+		case git.PullRequestOpen:
+			if filter.matches(pr) {
+				logger.Info("keeping unlisted open pull request", "number", n)
+				current[number] = last[number]
+				continue
+			}
+			logger.Info("pull request no longer matches", "number", n)
+		default:
+			logger.Info("ignoring pull request in unknown state", "number", n, "state", pr.State)
+		}
+	}
+
+	delivered := fmt.Sprintf("%d events", len(events))
+	if len(events) == 1 {
+		delivered = fmt.Sprintf("the event for pull request %v", events[0].Data["number"])
+	}
+	return pollResult{
+		pullRequests: current,
+		changed:      !maps.Equal(last, current),
+		events:       events,
+		message:      fmt.Sprintf("polled %d pull requests", len(current)),
+		delivered:    delivered,
+	}, nil
+}
+
+func pullRequestEvent(eventType string, pr git.PullRequest) cloudevents.Event {
+	return cloudevents.Event{Type: eventType, Ref: pr.HeadRef, Data: pr.Commit()}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/gitpoller-controller/pkg/cloudevents"
+	"github.com/gitops-tools/gitpoller-controller/pkg/git"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+)
+
+func TestPollPullRequestsWithLockedMergeRequest(t *testing.T) {
+	opened := git.PullRequest{Number: 1, State: git.PullRequestOpen, HeadRef: "feature", HeadSHA: "sha-1", BaseRef: "main"}
+	// GitLab merge requests aren't listed as opened while they're locked
+	// during the merge, but the state of the merge request is still open.
+	locked := opened
+	merged := opened
+	merged.State = git.PullRequestMerged
+
+	steps := []struct {
+		name        string
+		lister      mockPullRequestLister
+		wantStatus  map[string]string
+		wantChanged bool
+		wantEvents  []string
+	}{
+		{
+			name:        "opened",
+			lister:      mockPullRequestLister{open: []git.PullRequest{opened}},
+			wantStatus:  map[string]string{"1": "sha-1"},
+			wantChanged: true,
+			wantEvents:  []string{cloudevents.PullRequestOpenedEvent},
+		},
+		{
+			name:       "locked",
+			lister:     mockPullRequestLister{pullRequests: map[int]git.PullRequest{1: locked}},
+			wantStatus: map[string]string{"1": "sha-1"},
+		},
+		{
+			name:        "merged",
+			lister:      mockPullRequestLister{pullRequests: map[int]git.PullRequest{1: merged}},
+			wantStatus:  map[string]string{},
+			wantChanged: true,
+			wantEvents:  []string{cloudevents.PullRequestMergedEvent},
+		},
+	}
+
+	var last map[string]string
+	for _, step := range steps {
+		result, err := pollPullRequests(context.TODO(), step.lister, "testing/repo", pullRequestFilter{}, last)
+		utils.AssertNoError(t, err)
+
+		if diff := cmp.Diff(step.wantStatus, result.pullRequests); diff != "" {
+			t.Errorf("%s: incorrect pull requests:\n%s", step.name, diff)
+		}
+		if result.changed != step.wantChanged {
+			t.Errorf("%s: got changed %v, want %v", step.name, result.changed, step.wantChanged)
+		}
+		var events []string
+		for _, event := range result.events {
+			events = append(events, event.Type)
+		}
+		if diff := cmp.Diff(step.wantEvents, events); diff != "" {
+			t.Errorf("%s: incorrect events:\n%s", step.name, diff)
+		}
+		last = result.pullRequests
+	}
+}
+
+func TestPollPullRequestsWithDeletedPullRequest(t *testing.T) {
+	last := map[string]string{"1": "sha-1", "2": "sha-2"}
+	lister := mockPullRequestLister{
+		open: []git.PullRequest{{Number: 2, State: git.PullRequestOpen, HeadRef: "feature", HeadSHA: "sha-2", BaseRef: "main"}},
+	}
+
+	result, err := pollPullRequests(context.TODO(), lister, "testing/repo", pullRequestFilter{}, last)
+	utils.AssertNoError(t, err)
+
+	if diff := cmp.Diff(map[string]string{"2": "sha-2"}, result.pullRequests); diff != "" {
+		t.Errorf("incorrect pull requests:\n%s", diff)
+	}
+	if !result.changed {
+		t.Error("expected the pull requests to have changed")
+	}
+	want := []cloudevents.Event{
+		{
+			Type: cloudevents.PullRequestClosedEvent,
+			Data: map[string]any{
+				"number": 1, "title": "", "url": "", "state": git.PullRequestClosed,
+				"headRef": "", "headSHA": "sha-1", "baseRef": "", "labels": []string(nil),
+			},
+		},
+	}
+	if diff := cmp.Diff(want, result.events); diff != "" {
+		t.Errorf("incorrect events:\n%s", diff)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/pkg/git"
+)
+
+// anonymousCredential is the credential for repositories with no auth.
+const anonymousCredential = "anonymous"
+
+var (
+	rateLimitLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitpoller_rate_limit_limit",
+		Help: "The number of requests allowed in the rate limit window for the host and credential.",
+	}, []string{"host", "credential"})
+	rateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitpoller_rate_limit_remaining",
+		Help: "The number of requests remaining in the rate limit window for the host and credential.",
+	}, []string{"host", "credential"})
+	rateLimitReset = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gitpoller_rate_limit_reset_timestamp_seconds",
+		Help: "The Unix time when the rate limit window resets for the host and credential.",
+	}, []string{"host", "credential"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(rateLimitLimit, rateLimitRemaining, rateLimitReset)
+}
+
+// rateLimitKey identifies the requests that share a rate limit.
+//
+// The credential is the namespace/name of the secret, which is safe to use as
+// a metric label.
+type rateLimitKey struct {
+	host       string
+	credential string
+}
+
+func rateLimitKeyFor(endpoint, namespace string, auth *pollingv1.AuthSecret) rateLimitKey {
+	key := rateLimitKey{host: endpoint, credential: anonymousCredential}
+	if parsed, err := url.Parse(endpoint); err == nil && parsed.Host != "" {
+		key.host = parsed.Host
+	}
+	if auth != nil {
+		key.credential = namespace + "/" + auth.SecretRef.Name
+	}
+	return key
+}
+
+// RateLimits records the rate limits reported by servers, so that polls for
+// all repositories that share a host and credential can be delayed until the
+// limit resets.
+type RateLimits struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	limits map[rateLimitKey]git.RateLimit
+}
+
+// NewRateLimits creates and returns a new RateLimits.
+func NewRateLimits() *RateLimits {
+	return &RateLimits{now: time.Now, limits: map[rateLimitKey]git.RateLimit{}}
+}
+
+// Record records the latest rate limit for the key and updates the metrics.
+func (r *RateLimits) Record(key rateLimitKey, limit git.RateLimit) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.limits[key] = limit
+	r.mu.Unlock()
+
+	if limit.Limit > 0 {
+		rateLimitLimit.WithLabelValues(key.host, key.credential).Set(float64(limit.Limit))
+	}
+	rateLimitRemaining.WithLabelValues(key.host, key.credential).Set(float64(limit.Remaining))
+	if !limit.Reset.IsZero() {
+		rateLimitReset.WithLabelValues(key.host, key.credential).Set(float64(limit.Reset.Unix()))
+	}
+}
+
+// Delay returns how long to wait before polling with the key, this is zero
+// unless the rate limit is exhausted.
+func (r *RateLimits) Delay(key rateLimitKey) time.Duration {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	limit, ok := r.limits[key]
+	now := r.now()
+	if !ok || !limit.Exhausted(now) {
+		return 0
+	}
+	return limit.Reset.Sub(now)
+}
+
+// Client returns a copy of the client that records the rate limits from the
+// responses.
+func (r *RateLimits) Client(c *http.Client, key rateLimitKey) *http.Client {
+	if r == nil {
+		return c
+	}
+	if c == nil {
+		c = http.DefaultClient
+	}
+	transport := c.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	recording := *c
+	recording.Transport = rateLimitTransport{base: transport, limits: r, key: key}
+	return &recording
+}
+
+type rateLimitTransport struct {
+	base   http.RoundTripper
+	limits *RateLimits
+	key    rateLimitKey
+}
+
+func (t rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if limit, ok := git.ParseRateLimit(resp.Header, t.limits.now()); ok {
+		t.limits.Record(t.key, limit)
+	}
+	return resp, nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+
+	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/pkg/git"
+)
+
+func TestRateLimits(t *testing.T) {
+	now := time.Date(2024, time.March, 12, 9, 0, 0, 0, time.UTC)
+	limits := NewRateLimits()
+	limits.now = func() time.Time { return now }
+	key := rateLimitKey{host: "api.github.com", credential: "testing/github-token"}
+
+	if d := limits.Delay(key); d != 0 {
+		t.Errorf("Delay() for unknown key got %s, want 0", d)
+	}
+
+	limits.Record(key, git.RateLimit{Limit: 5000, Remaining: 10, Reset: now.Add(time.Hour)})
+	if d := limits.Delay(key); d != 0 {
+		t.Errorf("Delay() with remaining requests got %s, want 0", d)
+	}
+	assertGaugeValue(t, rateLimitRemaining, key, 10)
+	assertGaugeValue(t, rateLimitLimit, key, 5000)
+	assertGaugeValue(t, rateLimitReset, key, float64(now.Add(time.Hour).Unix()))
+
+	limits.Record(key, git.RateLimit{Limit: 5000, Remaining: 0, Reset: now.Add(time.Hour)})
+	if d := limits.Delay(key); d != time.Hour {
+		t.Errorf("Delay() when exhausted got %s, want %s", d, time.Hour)
+	}
+	other := rateLimitKey{host: "api.github.com", credential: anonymousCredential}
+	if d := limits.Delay(other); d != 0 {
+		t.Errorf("Delay() for another credential got %s, want 0", d)
+	}
+
+	now = now.Add(time.Hour)
+	if d := limits.Delay(key); d != 0 {
+		t.Errorf("Delay() after the reset got %s, want 0", d)
+	}
+}
+
+func TestRateLimitsClient(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "60")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	}))
+	t.Cleanup(ts.Close)
+	limits := NewRateLimits()
+	key := rateLimitKey{host: "example.com", credential: anonymousCredential}
+
+	resp, err := limits.Client(ts.Client(), key).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if d := limits.Delay(key); d <= 0 {
+		t.Errorf("Delay() got %s, want the time until the reset", d)
+	}
+	assertGaugeValue(t, rateLimitLimit, key, 60)
+}
+
+func TestRateLimitsWithNoRateLimits(t *testing.T) {
+	var limits *RateLimits
+	key := rateLimitKey{host: "example.com", credential: anonymousCredential}
+
+	limits.Record(key, git.RateLimit{Reset: time.Now().Add(time.Hour)})
+
+	if d := limits.Delay(key); d != 0 {
+		t.Errorf("Delay() got %s, want 0", d)
+	}
+	if c := limits.Client(http.DefaultClient, key); c != http.DefaultClient {
+		t.Errorf("Client() got %#v, want the default client", c)
+	}
+}
+
+func TestRateLimitKeyFor(t *testing.T) {
+	keyTests := []struct {
+		endpoint string
+		auth     *pollingv1.AuthSecret
+		want     rateLimitKey
+	}{
+		{"https://api.github.com", nil, rateLimitKey{host: "api.github.com", credential: anonymousCredential}},
+		{"https://gitlab.example.com", &pollingv1.AuthSecret{SecretRef: corev1.LocalObjectReference{Name: "gitlab-token"}}, rateLimitKey{host: "gitlab.example.com", credential: "testing/gitlab-token"}},
+		{"https://github.example.com/api/v3", &pollingv1.AuthSecret{SecretRef: corev1.LocalObjectReference{Name: "github-app"}}, rateLimitKey{host: "github.example.com", credential: "testing/github-app"}},
+	}
+
+	for _, tt := range keyTests {
+		if got := rateLimitKeyFor(tt.endpoint, testNamespace, tt.auth); got != tt.want {
+			t.Errorf("rateLimitKeyFor(%q) got %#v, want %#v", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func assertGaugeValue(t *testing.T, g *prometheus.GaugeVec, key rateLimitKey, want float64) {
+	t.Helper()
+	var m dto.Metric
+	if err := g.WithLabelValues(key.host, key.credential).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetGauge().GetValue(); got != want {
+		t.Errorf("gauge got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+
+	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/pkg/cloudevents"
+	"github.com/gitops-tools/gitpoller-controller/pkg/git"
+)
+
+// regexRefPrefix is the prefix for Refs that are regular expressions rather
+// than globs.
+const regexRefPrefix = "regex:"
+
+// refPattern returns true if the branch name matches.
+type refPattern func(name string) bool
+
+func parseRefPatterns(refs []string) ([]refPattern, error) {
+	var patterns []refPattern
+	for _, ref := range refs {
+		if expr, ok := strings.CutPrefix(ref, regexRefPrefix); ok {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+			}
+			patterns = append(patterns, re.MatchString)
+			continue
+		}
+		// Match only reports an invalid pattern when it's checked.
+		if _, err := path.Match(ref, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", ref, err)
+		}
+		patterns = append(patterns, func(name string) bool {
+			matched, _ := path.Match(ref, name)
+			return matched
+		})
+	}
+	return patterns, nil
+}
+
+// refPollerFor parses the Refs, the poller must be able to list the branches.
+func refPollerFor(poller git.CommitPoller, repoType pollingv1.RepoType, refs []string) ([]refPattern, git.BranchLister, error) {
+	lister, ok := poller.(git.BranchLister)
+	if !ok {
+		return nil, nil, fmt.Errorf("refs are not supported for the %q type", repoType)
+	}
+	patterns, err := parseRefPatterns(refs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return patterns, lister, nil
+}
+
+func matchesAny(patterns []refPattern, name string) bool {
+	for _, matches := range patterns {
+		if matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// pollRefs lists the branches, and polls each branch that matches the
+// patterns when its SHA differs from the last poll.
+//
+// There's a commit event for each branch that changed, branches that match
+// for the first time also have a ref.created event before the commit event,
+// and branches that were deleted, or no longer match, have a ref.deleted
+// event and are removed from the status.
+//
+// If the branches are truncated, the branches that weren't listed may still
+// exist, and are kept without an event.
+func pollRefs(ctx context.Context, lister git.BranchLister, poller git.CommitPoller, repoName string, patterns []refPattern, last map[string]pollingv1.PollStatus) (pollResult, error) {
+	logger := logr.FromContextOrDiscard(ctx)
+	branches, err := lister.ListBranches(ctx, repoName)
+	if err != nil {
+		return pollResult{}, err
+	}
+
+	refs := map[string]pollingv1.PollStatus{}
+	var events []cloudevents.Event
+	for _, branch := range branches.Items {
+		if !matchesAny(patterns, branch.Name) {
+			continue
+		}
+		previous, ok := last[branch.Name]
+		if ok && previous.SHA == branch.SHA {
+			refs[branch.Name] = previous
+			continue
+		}
+		if !ok {
+			previous = pollingv1.PollStatus{Ref: branch.Name}
+			events = append(events, cloudevents.Event{
+				Type: cloudevents.RefCreatedEvent,
+				Ref:  branch.Name,
+				Data: map[string]any{"ref": branch.Name, "sha": branch.SHA},
+			})
+		}
+		newStatus, commit, err := poller.Poll(ctx, repoName, previous)
+		if err != nil {
+			return pollResult{}, fmt.Errorf("failed to poll ref %q: %w", branch.Name, err)
+		}
+		logger.Info("polled", "status", newStatus)
+		refs[branch.Name] = newStatus
+		// The commit may not have changed if the branch moved after listing.
+		if newStatus.SHA != previous.SHA {
+			events = append(events, cloudevents.Event{Type: cloudevents.CommitEvent, Ref: branch.Name, Data: commit})
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(last)) {
+		if _, ok := refs[name]; ok {
+			continue
+		}
+		if branches.Truncated {
+			logger.Info("keeping unlisted ref, the branches are truncated", "ref", name)
+			refs[name] = last[name]
+			continue
+		}
+		events = append(events, cloudevents.Event{
+			Type: cloudevents.RefDeletedEvent,
+			Ref:  name,
+			Data: map[string]any{"ref": name, "sha": last[name].SHA},
+		})
+	}
+
+	delivered := fmt.Sprintf("%d events", len(events))
+	if len(events) == 1 {
+		delivered = fmt.Sprintf("the event for ref %q", events[0].Ref)
+	}
+	return pollResult{
+		refs:      refs,
+		changed:   !maps.EqualFunc(last, refs, pollingv1.PollStatus.Equal),
+		events:    events,
+		message:   fmt.Sprintf("polled %d refs", len(refs)),
+		delivered: delivered,
+	}, nil
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+)
+
+func TestParseRefPatterns(t *testing.T) {
+	matchTests := []struct {
+		refs []string
+		name string
+		want bool
+	}{
+		{[]string{"main"}, "main", true},
+		{[]string{"main"}, "maintenance", false},
+		{[]string{"release/*"}, "release/1.0", true},
+		{[]string{"release/*"}, "release/1.0/hotfix", false},
+		{[]string{"release/*"}, "releases", false},
+		{[]string{"main", "release-[0-9]*"}, "release-12", true},
+		{[]string{"regex:^release/v[0-9]+$"}, "release/v2", true},
+		{[]string{"regex:^release/v[0-9]+$"}, "release/v2-rc", false},
+		{[]string{"regex:hotfix"}, "release/1.0/hotfix", true},
+	}
+
+	for _, tt := range matchTests {
+		patterns, err := parseRefPatterns(tt.refs)
+		utils.AssertNoError(t, err)
+
+		if got := matchesAny(patterns, tt.name); got != tt.want {
+			t.Errorf("matchesAny(%q, %q) got %v, want %v", tt.refs, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRefPatternsErrors(t *testing.T) {
+	errorTests := []struct {
+		refs []string
+		want string
+	}{
+		{[]string{"release/["}, `invalid glob "release/\["`},
+		{[]string{"main", "regex:release/("}, `invalid regular expression "release/\("`},
+	}
+
+	for _, tt := range errorTests {
+		_, err := parseRefPatterns(tt.refs)
+		utils.AssertErrorMatch(t, tt.want, err)
+	}
+}
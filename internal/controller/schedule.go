@@ -0,0 +1,257 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+)
+
+// windowSearchDays is how far ahead to look for a time that the windows allow
+// polling, windows repeat weekly so this covers every window.
+const windowSearchDays = 8
+
+var weekdays = map[pollingv1.Weekday]time.Weekday{
+	"Sun": time.Sunday,
+	"Mon": time.Monday,
+	"Tue": time.Tuesday,
+	"Wed": time.Wednesday,
+	"Thu": time.Thursday,
+	"Fri": time.Friday,
+	"Sat": time.Saturday,
+}
+
+// pollSchedule calculates when to poll a repository from the frequency, or
+// the cron schedules, and the windows.
+type pollSchedule struct {
+	crons   []cron.Schedule
+	windows []pollWindow
+}
+
+// pollWindow is a parsed PollWindow, the start and end are the time since
+// midnight.
+type pollWindow struct {
+	windowType pollingv1.WindowType
+	start      time.Duration
+	end        time.Duration
+	days       []time.Weekday
+	location   *time.Location
+}
+
+func newPollSchedule(spec pollingv1.PolledRepositorySpec) (*pollSchedule, error) {
+	s := &pollSchedule{}
+	for _, schedule := range spec.Schedule {
+		loc, err := time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time zone for schedule %q: %w", schedule.Cron, err)
+		}
+		parsed, err := cron.ParseStandard(schedule.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", schedule.Cron, err)
+		}
+		if spec, ok := parsed.(*cron.SpecSchedule); ok {
+			spec.Location = loc
+		}
+		s.crons = append(s.crons, parsed)
+	}
+
+	for _, window := range spec.Windows {
+		parsed, err := parsePollWindow(window)
+		if err != nil {
+			return nil, err
+		}
+		s.windows = append(s.windows, parsed)
+	}
+	// The windows repeat weekly, so if they don't allow polling in the week
+	// from any time, they never do.
+	if _, ok := s.nextAllowed(time.Unix(0, 0)); !ok {
+		return nil, errors.New("the windows never allow polling")
+	}
+
+	return s, nil
+}
+
+func parsePollWindow(w pollingv1.PollWindow) (pollWindow, error) {
+	loc, err := time.LoadLocation(w.TimeZone)
+	if err != nil {
+		return pollWindow{}, fmt.Errorf("invalid time zone for window %s-%s: %w", w.Start, w.End, err)
+	}
+	start, err := parseTimeOfDay(w.Start)
+	if err != nil {
+		return pollWindow{}, err
+	}
+	end, err := parseTimeOfDay(w.End)
+	if err != nil {
+		return pollWindow{}, err
+	}
+	if end <= start {
+		end += 24 * time.Hour
+	}
+	var days []time.Weekday
+	for _, day := range w.Days {
+		weekday, ok := weekdays[day]
+		if !ok {
+			return pollWindow{}, fmt.Errorf("invalid day %q for window %s-%s", day, w.Start, w.End)
+		}
+		days = append(days, weekday)
+	}
+
+	return pollWindow{windowType: w.Type, start: start, end: end, days: days, location: loc}, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// interval returns the time until the next scheduled poll, if there are no
+// cron schedules, this is the frequency.
+func (s *pollSchedule) interval(now time.Time, frequency time.Duration) time.Duration {
+	var next time.Time
+	for _, c := range s.crons {
+		n := c.Next(now)
+		// The cron library returns the zero time if there is no match.
+		if !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	if next.IsZero() {
+		return frequency
+	}
+	return next.Sub(now)
+}
+
+// allowed returns true if the windows allow polling at t.
+func (s *pollSchedule) allowed(t time.Time) bool {
+	var hasAllow, inAllow bool
+	for _, w := range s.windows {
+		switch w.windowType {
+		case pollingv1.DenyWindow:
+			if w.contains(t) {
+				return false
+			}
+		case pollingv1.AllowWindow:
+			hasAllow = true
+			inAllow = inAllow || w.contains(t)
+		}
+	}
+	return !hasAllow || inAllow
+}
+
+// nextAllowed returns the first time from t that the windows allow polling,
+// and false if the windows never allow polling.
+func (s *pollSchedule) nextAllowed(t time.Time) (time.Time, bool) {
+	if s.allowed(t) {
+		return t, true
+	}
+	// Polling can only become allowed when an allow window starts, or a deny
+	// window ends.
+	var candidates []time.Time
+	for _, w := range s.windows {
+		// The window that started the day before may end today.
+		for day := -1; day < windowSearchDays; day++ {
+			start, end := w.on(t.In(w.location).AddDate(0, 0, day))
+			boundary := start
+			if w.windowType == pollingv1.DenyWindow {
+				boundary = end
+			}
+			if boundary.After(t) {
+				candidates = append(candidates, boundary)
+			}
+		}
+	}
+	slices.SortFunc(candidates, func(a, b time.Time) int { return a.Compare(b) })
+	for _, candidate := range candidates {
+		if s.allowed(candidate) {
+			return candidate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// contains returns true if t is within the window, the window may have
+// started the day before.
+func (w pollWindow) contains(t time.Time) bool {
+	for _, day := range []int{0, -1} {
+		start, end := w.on(t.In(w.location).AddDate(0, 0, day))
+		if w.appliesOn(start.Weekday()) && !t.Before(start) && t.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// on returns the start and end of the window that starts on the same day as
+// t in the window's time zone.
+func (w pollWindow) on(t time.Time) (time.Time, time.Time) {
+	// This uses the wall clock time on days when the clocks change.
+	y, m, d := t.In(w.location).Date()
+	return time.Date(y, m, d, 0, int(w.start.Minutes()), 0, 0, w.location), time.Date(y, m, d, 0, int(w.end.Minutes()), 0, 0, w.location)
+}
+
+func (w pollWindow) appliesOn(day time.Weekday) bool {
+	return len(w.days) == 0 || slices.Contains(w.days, day)
+}
+
+// frequencyFor returns the frequency to poll the repository at, this is the
+// effective frequency when adaptive polling is enabled.
+func frequencyFor(repo *pollingv1.PolledRepository) time.Duration {
+	minFrequency, maxFrequency, ok := adaptiveFrequencies(repo.Spec)
+	if !ok {
+		return repo.Spec.Frequency.Duration
+	}
+	if repo.Status.EffectiveFrequency == nil {
+		return minFrequency
+	}
+	return min(max(repo.Status.EffectiveFrequency.Duration, minFrequency), maxFrequency)
+}
+
+// adaptFrequency updates the effective frequency after a successful poll.
+//
+// When the ref changes, the effective frequency is reset to the minimum, and
+// while it's unchanged, it doubles with each poll up to the maximum.
+func adaptFrequency(repo *pollingv1.PolledRepository, changed bool) {
+	minFrequency, maxFrequency, ok := adaptiveFrequencies(repo.Spec)
+	if !ok {
+		repo.Status.EffectiveFrequency = nil
+		return
+	}
+	next := minFrequency
+	if !changed && repo.Status.EffectiveFrequency != nil {
+		next = min(max(repo.Status.EffectiveFrequency.Duration*2, minFrequency), maxFrequency)
+	}
+	repo.Status.EffectiveFrequency = &metav1.Duration{Duration: next}
+}
+
+// adaptiveFrequencies returns the minimum and maximum frequencies, and false
+// if adaptive polling isn't enabled, or the minimum frequency isn't positive.
+func adaptiveFrequencies(spec pollingv1.PolledRepositorySpec) (time.Duration, time.Duration, bool) {
+	if spec.MinFrequency == nil || spec.MaxFrequency == nil || spec.MinFrequency.Duration <= 0 {
+		return 0, 0, false
+	}
+	return spec.MinFrequency.Duration, max(spec.MinFrequency.Duration, spec.MaxFrequency.Duration), true
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pollingv1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+)
+
+// 2024-03-12 is a Tuesday.
+var testScheduleTime = time.Date(2024, time.March, 12, 9, 20, 0, 0, time.UTC)
+
+func TestPollScheduleInterval(t *testing.T) {
+	intervalTests := []struct {
+		name     string
+		schedule []pollingv1.PollSchedule
+		want     time.Duration
+	}{
+		{"no schedule", nil, time.Minute * 5},
+		{"hourly", []pollingv1.PollSchedule{{Cron: "0 * * * *"}}, time.Minute * 40},
+		{"descriptor", []pollingv1.PollSchedule{{Cron: "@daily"}}, time.Hour*14 + time.Minute*40},
+		{"earliest of the schedules", []pollingv1.PollSchedule{{Cron: "0 * * * *"}, {Cron: "*/15 * * * *"}}, time.Minute * 10},
+		{"business hours and hourly at night", []pollingv1.PollSchedule{{Cron: "* 9-16 * * 1-5"}, {Cron: "0 * * * *"}}, time.Minute},
+		{"time zone", []pollingv1.PollSchedule{{Cron: "0 9 * * *", TimeZone: "America/New_York"}}, time.Hour*3 + time.Minute*40},
+	}
+
+	for _, tt := range intervalTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mustParsePollSchedule(t, pollingv1.PolledRepositorySpec{Schedule: tt.schedule})
+
+			if got := s.interval(testScheduleTime, time.Minute*5); got != tt.want {
+				t.Errorf("interval() got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPollScheduleNextAllowed(t *testing.T) {
+	businessHours := pollingv1.PollWindow{Type: pollingv1.AllowWindow, Start: "09:00", End: "17:00", Days: []pollingv1.Weekday{"Mon", "Tue", "Wed", "Thu", "Fri"}}
+	nextAllowedTests := []struct {
+		name    string
+		windows []pollingv1.PollWindow
+		t       time.Time
+		want    time.Time
+	}{
+		{
+			"no windows",
+			nil,
+			testScheduleTime,
+			testScheduleTime,
+		},
+		{
+			"during an allow window",
+			[]pollingv1.PollWindow{businessHours},
+			testScheduleTime,
+			testScheduleTime,
+		},
+		{
+			"before an allow window",
+			[]pollingv1.PollWindow{businessHours},
+			time.Date(2024, time.March, 12, 8, 0, 0, 0, time.UTC),
+			time.Date(2024, time.March, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			"after an allow window on a Friday",
+			[]pollingv1.PollWindow{businessHours},
+			time.Date(2024, time.March, 15, 18, 0, 0, 0, time.UTC),
+			time.Date(2024, time.March, 18, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			"during a deny window that started the day before",
+			[]pollingv1.PollWindow{{Type: pollingv1.DenyWindow, Start: "22:00", End: "06:00"}},
+			time.Date(2024, time.March, 12, 1, 0, 0, 0, time.UTC),
+			time.Date(2024, time.March, 12, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			"during a deny window for the whole day",
+			[]pollingv1.PollWindow{{Type: pollingv1.DenyWindow, Start: "00:00", End: "00:00", Days: []pollingv1.Weekday{"Sat"}}},
+			time.Date(2024, time.March, 16, 10, 0, 0, 0, time.UTC),
+			time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"during a deny window within an allow window",
+			[]pollingv1.PollWindow{businessHours, {Type: pollingv1.DenyWindow, Start: "12:00", End: "13:00"}},
+			time.Date(2024, time.March, 12, 12, 30, 0, 0, time.UTC),
+			time.Date(2024, time.March, 12, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			"allow window in a time zone",
+			[]pollingv1.PollWindow{{Type: pollingv1.AllowWindow, Start: "09:00", End: "17:00", TimeZone: "Europe/Paris"}},
+			time.Date(2024, time.March, 12, 16, 30, 0, 0, time.UTC),
+			time.Date(2024, time.March, 13, 8, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range nextAllowedTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := mustParsePollSchedule(t, pollingv1.PolledRepositorySpec{Windows: tt.windows})
+
+			got, ok := s.nextAllowed(tt.t)
+			if !ok {
+				t.Fatal("nextAllowed() found no allowed time")
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("nextAllowed() got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPollScheduleErrors(t *testing.T) {
+	errorTests := []struct {
+		name string
+		spec pollingv1.PolledRepositorySpec
+		want string
+	}{
+		{"invalid cron", pollingv1.PolledRepositorySpec{Schedule: []pollingv1.PollSchedule{{Cron: "every minute"}}}, `invalid schedule "every minute"`},
+		{"invalid schedule time zone", pollingv1.PolledRepositorySpec{Schedule: []pollingv1.PollSchedule{{Cron: "@hourly", TimeZone: "Mars/Olympus_Mons"}}}, `invalid time zone for schedule "@hourly"`},
+		{"invalid window time", pollingv1.PolledRepositorySpec{Windows: []pollingv1.PollWindow{{Type: pollingv1.DenyWindow, Start: "25:00", End: "06:00"}}}, `invalid time of day "25:00"`},
+		{"invalid window day", pollingv1.PolledRepositorySpec{Windows: []pollingv1.PollWindow{{Type: pollingv1.DenyWindow, Start: "22:00", End: "06:00", Days: []pollingv1.Weekday{"Monday"}}}}, `invalid day "Monday"`},
+		{"windows never allow polling", pollingv1.PolledRepositorySpec{Windows: []pollingv1.PollWindow{{Type: pollingv1.DenyWindow, Start: "00:00", End: "00:00"}}}, "the windows never allow polling"},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPollSchedule(tt.spec)
+			utils.AssertErrorMatch(t, tt.want, err)
+		})
+	}
+}
+
+func TestAdaptFrequency(t *testing.T) {
+	adaptTests := []struct {
+		name    string
+		current *metav1.Duration
+		changed bool
+		want    time.Duration
+	}{
+		{"first poll", nil, true, time.Minute},
+		{"changed", &metav1.Duration{Duration: time.Minute * 16}, true, time.Minute},
+		{"unchanged", &metav1.Duration{Duration: time.Minute * 4}, false, time.Minute * 8},
+		{"unchanged at the maximum", &metav1.Duration{Duration: time.Hour}, false, time.Hour},
+		{"unchanged near the maximum", &metav1.Duration{Duration: time.Minute * 45}, false, time.Hour},
+		{"unchanged below the minimum", &metav1.Duration{Duration: time.Second * 10}, false, time.Minute},
+	}
+
+	for _, tt := range adaptTests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newPolledRepository(func(r *pollingv1.PolledRepository) {
+				r.Spec.MinFrequency = &metav1.Duration{Duration: time.Minute}
+				r.Spec.MaxFrequency = &metav1.Duration{Duration: time.Hour}
+				r.Status.EffectiveFrequency = tt.current
+			})
+
+			adaptFrequency(repo, tt.changed)
+
+			if got := repo.Status.EffectiveFrequency.Duration; got != tt.want {
+				t.Errorf("adaptFrequency() got %s, want %s", got, tt.want)
+			}
+			if got := frequencyFor(repo); got != tt.want {
+				t.Errorf("frequencyFor() got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrequencyFor(t *testing.T) {
+	repo := newPolledRepository()
+	if got := frequencyFor(repo); got != time.Minute*5 {
+		t.Errorf("frequencyFor() without adaptive polling got %s, want %s", got, time.Minute*5)
+	}
+
+	adaptFrequency(repo, false)
+	if repo.Status.EffectiveFrequency != nil {
+		t.Errorf("got EffectiveFrequency %s without adaptive polling", repo.Status.EffectiveFrequency)
+	}
+
+	repo.Spec.MinFrequency = &metav1.Duration{Duration: time.Minute}
+	repo.Spec.MaxFrequency = &metav1.Duration{Duration: time.Hour}
+	if got := frequencyFor(repo); got != time.Minute {
+		t.Errorf("frequencyFor() before the first poll got %s, want %s", got, time.Minute)
+	}
+}
+
+func TestFrequencyForWithZeroMinFrequency(t *testing.T) {
+	repo := newPolledRepository()
+	repo.Spec.MinFrequency = &metav1.Duration{}
+	repo.Spec.MaxFrequency = &metav1.Duration{Duration: time.Hour}
+
+	adaptFrequency(repo, true)
+	if repo.Status.EffectiveFrequency != nil {
+		t.Errorf("got EffectiveFrequency %s with a zero minFrequency", repo.Status.EffectiveFrequency)
+	}
+	if got := frequencyFor(repo); got != time.Minute*5 {
+		t.Errorf("frequencyFor() with a zero minFrequency got %s, want %s", got, time.Minute*5)
+	}
+}
+
+func mustParsePollSchedule(t *testing.T, spec pollingv1.PolledRepositorySpec) *pollSchedule {
+	t.Helper()
+	s, err := newPollSchedule(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -19,6 +19,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2"
@@ -29,13 +31,50 @@ import (
 
 // TODO: Maybe switch to an event dispatcher in the style of http.HandlerFunc?
 
+// These are the types of event.
+const (
+	// CommitEvent is dispatched when the commit for a ref changes.
+	CommitEvent = "commit"
+	// RefCreatedEvent is dispatched when a ref that matches the Refs is
+	// created.
+	RefCreatedEvent = "ref.created"
+	// RefDeletedEvent is dispatched when a ref that matched the Refs is
+	// deleted.
+	RefDeletedEvent = "ref.deleted"
+	// PullRequestOpenedEvent is dispatched when a pull request that matches
+	// the PullRequests policy is first seen.
+	PullRequestOpenedEvent = "pull_request.opened"
+	// PullRequestUpdatedEvent is dispatched when the head SHA of a pull
+	// request changes.
+	PullRequestUpdatedEvent = "pull_request.updated"
+	// PullRequestClosedEvent is dispatched when a pull request is closed
+	// without being merged.
+	PullRequestClosedEvent = "pull_request.closed"
+	// PullRequestMergedEvent is dispatched when a pull request is merged.
+	PullRequestMergedEvent = "pull_request.merged"
+	// ReleaseEvent is dispatched when the release selected by the Releases
+	// policy changes.
+	ReleaseEvent = "release"
+)
+
+// Event is an event for a ref in a repository.
+type Event struct {
+	// Type is the CloudEvent type e.g. CommitEvent.
+	Type string
+	// Ref is the ref that the event is for, this is sent in the "ref"
+	// extension attribute.
+	Ref string
+	// Data is the body of the event e.g. the commit.
+	Data map[string]any
+}
+
 // CloudEventDispatcher dispatches events via CloudEvents.
 type CloudEventDispatcher struct {
 }
 
-// Dispatch sends the commit as a CloudEvent to the Endpoint provided by the
+// Dispatch sends the event as a CloudEvent to the Endpoint provided by the
 // repo.
-func (c CloudEventDispatcher) Dispatch(ctx context.Context, repo pollingv1alpha1.PolledRepository, commit map[string]any) error {
+func (c CloudEventDispatcher) Dispatch(ctx context.Context, repo pollingv1alpha1.PolledRepository, e Event) error {
 	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", repo.Spec.Endpoint)
 	var useOnceTransport http.RoundTripper = &http.Transport{
 		DisableKeepAlives: true,
@@ -53,7 +92,7 @@ func (c CloudEventDispatcher) Dispatch(ctx context.Context, repo pollingv1alpha1
 		return fmt.Errorf("failed to create cloud event client: %w", err)
 	}
 
-	event, err := makeCloudEvent(repo, commit)
+	event, err := makeCloudEvent(repo, e)
 	if err != nil {
 		return fmt.Errorf("failed to create CloudEvent: %w", err)
 	}
@@ -70,18 +109,34 @@ func (c CloudEventDispatcher) Dispatch(ctx context.Context, repo pollingv1alpha1
 // TODO: Change to a CDEvent.
 // https://github.com/cdevents/spec/blob/v0.4.1/spec.md
 
-func makeCloudEvent(repo pollingv1alpha1.PolledRepository, commit map[string]interface{}) (*cloudevents.Event, error) {
+func makeCloudEvent(repo pollingv1alpha1.PolledRepository, e Event) (*cloudevents.Event, error) {
 	event := cloudevents.NewEvent()
 	event.SetID(uuid.New().String())
 	event.SetSubject(subjectForRepo(repo))
-	event.SetSource(repo.Spec.URL)
-	event.SetType("commit")
-	if err := event.SetData(cloudevents.ApplicationJSON, commit); err != nil {
+	event.SetSource(sourceForURL(repo.Spec.URL))
+	event.SetType(e.Type)
+	if e.Ref != "" {
+		event.SetExtension("ref", e.Ref)
+	}
+	if err := event.SetData(cloudevents.ApplicationJSON, e.Data); err != nil {
 		return nil, err
 	}
 	return &event, nil
 }
 
+// scpURLRE matches scp-style URLs e.g. git@github.com:org/repo.git
+var scpURLRE = regexp.MustCompile(`^([A-Za-z0-9._-]+)@([A-Za-z0-9.-]+):(.+)$`)
+
+// sourceForURL returns the repository URL as a URI reference for the event
+// source, scp-style URLs can't be parsed as a URI and are converted to
+// ssh://user@host/path.
+func sourceForURL(s string) string {
+	if m := scpURLRE.FindStringSubmatch(s); m != nil && !strings.Contains(s, "://") {
+		return fmt.Sprintf("ssh://%s@%s/%s", m[1], m[2], strings.TrimPrefix(m[3], "/"))
+	}
+	return s
+}
+
 func subjectForRepo(repo pollingv1alpha1.PolledRepository) string {
 	source := repo.GetObjectMeta().GetSelfLink()
 	if source == "" {
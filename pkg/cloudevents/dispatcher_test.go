@@ -39,6 +39,7 @@ func TestDispatch(t *testing.T) {
 			"Ce-Subject": "/apis/polling.gitops.tools/v1alpha1/namespaces/testing/PolledRepository/test-repository",
 			"Ce-Source":  repoURL,
 			"Ce-Type":    "commit",
+			"Ce-Ref":     "main",
 		})
 	}))
 	defer ts.Close()
@@ -59,7 +60,7 @@ func TestDispatch(t *testing.T) {
 		},
 	}
 
-	err := CloudEventDispatcher{}.Dispatch(context.TODO(), repo, event)
+	err := CloudEventDispatcher{}.Dispatch(context.TODO(), repo, Event{Type: CommitEvent, Ref: "main", Data: event})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -90,12 +91,70 @@ func TestDispatch_handle_non_200_response(t *testing.T) {
 		},
 	}
 
-	err := CloudEventDispatcher{}.Dispatch(context.TODO(), repo, event)
+	err := CloudEventDispatcher{}.Dispatch(context.TODO(), repo, Event{Type: CommitEvent, Ref: "main", Data: event})
 	if err == nil {
 		t.Fatal("expected an error response from an internal server error")
 	}
 }
 
+func TestMakeCloudEvent(t *testing.T) {
+	repo := pollingv1alpha1.PolledRepository{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "polling.gitops.tools/v1alpha1",
+			Kind:       "PolledRepository",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-repository",
+			Namespace: "testing",
+		},
+		Spec: pollingv1alpha1.PolledRepositorySpec{
+			URL:  "https://github.com/gitops-tools/gitpoller-controller",
+			Refs: []string{"release/*"},
+		},
+	}
+
+	for _, eventType := range []string{CommitEvent, RefCreatedEvent, RefDeletedEvent} {
+		t.Run(eventType, func(t *testing.T) {
+			event, err := makeCloudEvent(repo, Event{Type: eventType, Ref: "release/1.0", Data: map[string]any{"ref": "release/1.0"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if event.Type() != eventType {
+				t.Errorf("got type %q, want %q", event.Type(), eventType)
+			}
+			if ref := event.Extensions()["ref"]; ref != "release/1.0" {
+				t.Errorf("got ref extension %q, want %q", ref, "release/1.0")
+			}
+		})
+	}
+}
+
+func TestMakeCloudEventWithSCPURL(t *testing.T) {
+	repo := pollingv1alpha1.PolledRepository{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-repository",
+			Namespace: "testing",
+		},
+		Spec: pollingv1alpha1.PolledRepositorySpec{
+			URL: "git@github.com:gitops-tools/gitpoller-controller.git",
+			Ref: "main",
+		},
+	}
+
+	event, err := makeCloudEvent(repo, Event{Type: CommitEvent, Ref: "main", Data: map[string]any{"sha": "24317a55785cd98d6c9bf50a5204bc6be17e7316"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := event.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ssh://git@github.com/gitops-tools/gitpoller-controller.git"; event.Source() != want {
+		t.Errorf("got source %q, want %q", event.Source(), want)
+	}
+}
+
 func assertJSONRequest(t *testing.T, req *http.Request, want map[string]interface{}) {
 	t.Helper()
 	b, err := io.ReadAll(req.Body)
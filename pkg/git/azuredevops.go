@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+const azureDevOpsAPIVersion = "7.1"
+
+// AzureDevOpsPoller polls Azure DevOps Repos.
+//
+// The repo is expected to be in the form "organization/project/repository",
+// for older "organization.visualstudio.com" and Azure DevOps Server hosts, it
+// is the path to the project followed by the repository name.
+//
+// Requests are authenticated with a Personal Access Token using basic auth,
+// the username is optional.
+type AzureDevOpsPoller struct {
+	client    *http.Client
+	endpoint  string
+	username  string
+	authToken string
+}
+
+// NewAzureDevOpsPoller creates and returns a new Azure DevOps poller.
+func NewAzureDevOpsPoller(c *http.Client, endpoint, username, authToken string) *AzureDevOpsPoller {
+	if endpoint == "" {
+		endpoint = "https://dev.azure.com"
+	}
+	return &AzureDevOpsPoller{client: c, endpoint: endpoint, username: username, authToken: authToken}
+}
+
+func (g AzureDevOpsPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	requestURL, err := makeAzureDevOpsURL(g.endpoint, repo, pr.Ref)
+	if err != nil {
+		logger.Error(err, "polling Azure DevOps repo")
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	logger.Info("polling Azure DevOps repo", "url", requestURL)
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to create request for Azure DevOps: %w", err)
+	}
+	if pr.ETag != "" {
+		req.Header.Add("If-None-Match", pr.ETag)
+	}
+	req.Header.Add("Accept", "application/json")
+	if g.authToken != "" {
+		req.SetBasicAuth(g.username, g.authToken)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "polling Azure DevOps repo")
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("failed to get current commit: %v", err))
+	}
+	logger.Info("polled Azure DevOps repo", "status", resp.StatusCode)
+	// Azure DevOps redirects unauthenticated requests to a sign-in page.
+	if resp.StatusCode == http.StatusNonAuthoritativeInfo {
+		return pollingv1alpha1.PollStatus{}, nil, &StatusError{StatusCode: resp.StatusCode}
+	}
+	if err := checkResponse(resp); err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return pr, nil, nil
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from Azure DevOps")
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("reading body from Azure DevOps: %w", err))
+	}
+	var commits azureDevOpsCommits
+	err = json.Unmarshal(body, &commits)
+	if err != nil {
+		logger.Error(err, "unmarshalling Azure DevOps response")
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	if len(commits.Value) == 0 {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrNotFound, fmt.Errorf("no commits found for ref %q", pr.Ref))
+	}
+	commit := commits.Value[0]
+	sha, ok := commit["commitId"].(string)
+	if !ok {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("no commit SHA found for ref %q", pr.Ref))
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha, ETag: resp.Header.Get("ETag")}, commit, nil
+}
+
+// azureDevOpsCommits is the response from the commits endpoint.
+type azureDevOpsCommits struct {
+	Count int      `json:"count"`
+	Value []Commit `json:"value"`
+}
+
+func makeAzureDevOpsURL(endpoint, repo, ref string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	idx := strings.LastIndex(repo, "/")
+	if idx == -1 {
+		return "", fmt.Errorf("invalid repository %q, expected organization/project/repository", repo)
+	}
+	project, repoName := repo[:idx], repo[idx+1:]
+
+	version, versionType := ref, "branch"
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		version = strings.TrimPrefix(ref, "refs/heads/")
+	case strings.HasPrefix(ref, "refs/tags/"):
+		version, versionType = strings.TrimPrefix(ref, "refs/tags/"), "tag"
+	}
+
+	parsed.Path = path.Join(parsed.Path, project, "_apis/git/repositories", repoName, "commits")
+	parsed.RawQuery = url.Values{
+		"searchCriteria.itemVersion.version":     []string{version},
+		"searchCriteria.itemVersion.versionType": []string{versionType},
+		"searchCriteria.$top":                    []string{"1"},
+		"api-version":                            []string{azureDevOpsAPIVersion},
+	}.Encode()
+	return parsed.String(), nil
+}
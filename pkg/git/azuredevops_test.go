@@ -0,0 +1,173 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+)
+
+var _ CommitPoller = (*AzureDevOpsPoller)(nil)
+
+func TestNewAzureDevOpsPoller(t *testing.T) {
+	newTests := []struct {
+		endpoint     string
+		wantEndpoint string
+	}{
+		{"", "https://dev.azure.com"},
+		{"https://example.visualstudio.com", "https://example.visualstudio.com"},
+	}
+
+	for _, tt := range newTests {
+		c := NewAzureDevOpsPoller(http.DefaultClient, tt.endpoint, "", "testToken")
+		if c.endpoint != tt.wantEndpoint {
+			t.Errorf("%#v got %#v, want %#v", tt.endpoint, c.endpoint, tt.wantEndpoint)
+		}
+	}
+}
+
+func TestMakeAzureDevOpsURL(t *testing.T) {
+	urlTests := []struct {
+		endpoint string
+		repo     string
+		ref      string
+		want     string
+	}{
+		{"https://dev.azure.com", "org/project/repo", "main", "https://dev.azure.com/org/project/_apis/git/repositories/repo/commits?api-version=7.1&searchCriteria.%24top=1&searchCriteria.itemVersion.version=main&searchCriteria.itemVersion.versionType=branch"},
+		{"https://dev.azure.com", "org/project/repo", "refs/heads/main", "https://dev.azure.com/org/project/_apis/git/repositories/repo/commits?api-version=7.1&searchCriteria.%24top=1&searchCriteria.itemVersion.version=main&searchCriteria.itemVersion.versionType=branch"},
+		{"https://dev.azure.com", "org/project/repo", "refs/tags/v1.0.0", "https://dev.azure.com/org/project/_apis/git/repositories/repo/commits?api-version=7.1&searchCriteria.%24top=1&searchCriteria.itemVersion.version=v1.0.0&searchCriteria.itemVersion.versionType=tag"},
+		{"https://org.visualstudio.com", "my project/repo", "main", "https://org.visualstudio.com/my%20project/_apis/git/repositories/repo/commits?api-version=7.1&searchCriteria.%24top=1&searchCriteria.itemVersion.version=main&searchCriteria.itemVersion.versionType=branch"},
+	}
+
+	for _, tt := range urlTests {
+		got, err := makeAzureDevOpsURL(tt.endpoint, tt.repo, tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("makeAzureDevOpsURL(%q, %q, %q) got %q, want %q", tt.endpoint, tt.repo, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestAzureDevOpsWithUnknownETag(t *testing.T) {
+	as := makeAzureDevOpsAPIServer(t, basicAuth("", testToken), "/org/project/_apis/git/repositories/repo/commits", "main", mustReadFile(t, "testdata/azuredevops_commits.json"))
+	t.Cleanup(as.Close)
+	g := NewAzureDevOpsPoller(as.Client(), as.URL, "", testToken)
+
+	polled, body, err := g.Poll(context.TODO(), "org/project/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.SHA != "be67f8871a4d2c75f13a51c1d3c30ac0d74d4ef4" {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, "be67f8871a4d2c75f13a51c1d3c30ac0d74d4ef4")
+	}
+	if m := body["comment"].(string); m != "Merged PR 42: Update the deployment configuration" {
+		t.Fatalf("got comment %q", m)
+	}
+}
+
+func TestAzureDevOpsWithUsername(t *testing.T) {
+	as := makeAzureDevOpsAPIServer(t, basicAuth(testUsername, testToken), "/org/project/_apis/git/repositories/repo/commits", "main", mustReadFile(t, "testdata/azuredevops_commits.json"))
+	t.Cleanup(as.Close)
+	g := NewAzureDevOpsPoller(as.Client(), as.URL, testUsername, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "org/project/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAzureDevOpsWithNoCommits(t *testing.T) {
+	as := makeAzureDevOpsAPIServer(t, basicAuth("", testToken), "/org/project/_apis/git/repositories/repo/commits", "main", []byte(`{"count":0,"value":[]}`))
+	t.Cleanup(as.Close)
+	g := NewAzureDevOpsPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "org/project/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != `no commits found for ref "main"` {
+		t.Fatal(err)
+	}
+}
+
+func TestAzureDevOpsWithMalformedCommit(t *testing.T) {
+	as := makeAzureDevOpsAPIServer(t, basicAuth("", testToken), "/org/project/_apis/git/repositories/repo/commits", "main", []byte(`{"count":1,"value":[{"commitId":null}]}`))
+	t.Cleanup(as.Close)
+	g := NewAzureDevOpsPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "org/project/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if !errors.Is(err, ErrMalformedResponse) {
+		t.Fatalf("got %v, want ErrMalformedResponse", err)
+	}
+}
+
+func TestAzureDevOpsWithNotFoundResponse(t *testing.T) {
+	as := makeAzureDevOpsAPIServer(t, basicAuth("", testToken), "/org/project/_apis/git/repositories/repo/commits", "main", nil)
+	t.Cleanup(as.Close)
+	g := NewAzureDevOpsPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "org/project/testing", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != "server error: 404" {
+		t.Fatal(err)
+	}
+}
+
+// Azure DevOps responds to unauthenticated requests with a 203 and a sign-in
+// page.
+func TestAzureDevOpsWithBadAuthentication(t *testing.T) {
+	as := makeAzureDevOpsAPIServer(t, basicAuth("", testToken), "/org/project/_apis/git/repositories/repo/commits", "main", nil)
+	t.Cleanup(as.Close)
+	g := NewAzureDevOpsPoller(as.Client(), as.URL, "", "anotherToken")
+
+	_, _, err := g.Poll(context.TODO(), "org/project/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != "server error: 203" {
+		t.Fatal(err)
+	}
+}
+
+// makeAzureDevOpsAPIServer is used during testing to create an HTTP server to
+// return fixtures if the request matches.
+func makeAzureDevOpsAPIServer(t *testing.T, authorized func(*http.Request) bool, wantPath, wantVersion string, response []byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != wantPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if v := r.URL.Query().Get("searchCriteria.itemVersion.version"); v != wantVersion {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if v := r.URL.Query().Get("api-version"); v != azureDevOpsAPIVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !authorized(r) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusNonAuthoritativeInfo)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(response); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+// BitbucketPoller polls Bitbucket Cloud.
+//
+// If a username is provided, requests are authenticated with basic auth
+// (app passwords), otherwise the token is sent as a bearer token (workspace,
+// project and repository access tokens).
+type BitbucketPoller struct {
+	client    *http.Client
+	endpoint  string
+	username  string
+	authToken string
+}
+
+// NewBitbucketPoller creates and returns a new Bitbucket Cloud poller.
+func NewBitbucketPoller(c *http.Client, endpoint, username, authToken string) *BitbucketPoller {
+	if endpoint == "" {
+		endpoint = "https://api.bitbucket.org"
+	}
+	return &BitbucketPoller{client: c, endpoint: endpoint, username: username, authToken: authToken}
+}
+
+func (g BitbucketPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	requestURL, err := makeBitbucketURL(g.endpoint, repo, pr.Ref)
+	if err != nil {
+		logger.Error(err, "polling Bitbucket repo")
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	logger.Info("polling Bitbucket repo", "url", requestURL)
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to create request for Bitbucket: %w", err)
+	}
+	if pr.ETag != "" {
+		req.Header.Add("If-None-Match", pr.ETag)
+	}
+	req.Header.Add("Accept", "application/json")
+	switch {
+	case g.username != "":
+		req.SetBasicAuth(g.username, g.authToken)
+	case g.authToken != "":
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", g.authToken))
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "polling Bitbucket repo")
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("failed to get current commit: %v", err))
+	}
+	logger.Info("polled Bitbucket repo", "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return pr, nil, nil
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from Bitbucket")
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("reading body from Bitbucket: %w", err))
+	}
+	var branch bitbucketBranch
+	err = json.Unmarshal(body, &branch)
+	if err != nil {
+		logger.Error(err, "unmarshalling Bitbucket response")
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	sha, ok := branch.Target["hash"].(string)
+	if !ok {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("no commit hash found for ref %q", pr.Ref))
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha, ETag: resp.Header.Get("ETag")}, branch.Target, nil
+}
+
+// bitbucketBranch is the response from the refs/branches endpoint, the Target
+// is the commit at the HEAD of the branch.
+type bitbucketBranch struct {
+	Name   string `json:"name"`
+	Target Commit `json:"target"`
+}
+
+func makeBitbucketURL(endpoint, repo, ref string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	parsed.Path = path.Join(parsed.Path, "2.0/repositories", repo, "refs/branches", ref)
+	return parsed.String(), nil
+}
@@ -0,0 +1,178 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+)
+
+const testUsername = "test-user"
+
+var _ CommitPoller = (*BitbucketPoller)(nil)
+
+func TestNewBitbucketPoller(t *testing.T) {
+	newTests := []struct {
+		endpoint     string
+		wantEndpoint string
+	}{
+		{"", "https://api.bitbucket.org"},
+		{"https://bb.example.com", "https://bb.example.com"},
+	}
+
+	for _, tt := range newTests {
+		c := NewBitbucketPoller(http.DefaultClient, tt.endpoint, "", "testToken")
+		if c.endpoint != tt.wantEndpoint {
+			t.Errorf("%#v got %#v, want %#v", tt.endpoint, c.endpoint, tt.wantEndpoint)
+		}
+	}
+}
+
+func TestBitbucketWithUnknownETag(t *testing.T) {
+	as := makeBitbucketAPIServer(t, bearerAuth(testToken), "/2.0/repositories/testing/repo/refs/branches/main", testEtag, mustReadFile(t, "testdata/bitbucket_branch.json"))
+	t.Cleanup(as.Close)
+	g := NewBitbucketPoller(as.Client(), as.URL, "", testToken)
+
+	polled, body, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.ETag != testEtag {
+		t.Errorf("Poll() ETag got %s, want %s", polled.ETag, testEtag)
+	}
+	if polled.SHA != "1c3b3e4a56c3c4d9d8f0a6e2b7b0f0d44a0c9a61" {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, "1c3b3e4a56c3c4d9d8f0a6e2b7b0f0d44a0c9a61")
+	}
+	if m := body["message"].(string); m != "Merged in feature/readme (pull request #12)\n" {
+		t.Fatalf("got message %q", m)
+	}
+}
+
+func TestBitbucketWithKnownTag(t *testing.T) {
+	as := makeBitbucketAPIServer(t, bearerAuth(testToken), "/2.0/repositories/testing/repo/refs/branches/main", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewBitbucketPoller(as.Client(), as.URL, "", testToken)
+
+	polled, body, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "main", ETag: testEtag})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.ETag != testEtag {
+		t.Fatalf("Poll() got %s, want %s", polled.ETag, testEtag)
+	}
+	if body != nil {
+		t.Fatalf("expected an empty body, got %#v\n", body)
+	}
+}
+
+func TestBitbucketWithAppPassword(t *testing.T) {
+	as := makeBitbucketAPIServer(t, basicAuth(testUsername, testToken), "/2.0/repositories/testing/repo/refs/branches/main", testEtag, mustReadFile(t, "testdata/bitbucket_branch.json"))
+	t.Cleanup(as.Close)
+	g := NewBitbucketPoller(as.Client(), as.URL, testUsername, testToken)
+
+	polled, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.SHA != "1c3b3e4a56c3c4d9d8f0a6e2b7b0f0d44a0c9a61" {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, "1c3b3e4a56c3c4d9d8f0a6e2b7b0f0d44a0c9a61")
+	}
+}
+
+func TestBitbucketWithNotFoundResponse(t *testing.T) {
+	as := makeBitbucketAPIServer(t, bearerAuth(testToken), "/2.0/repositories/testing/repo/refs/branches/main", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewBitbucketPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/testing", pollingv1alpha1.PollStatus{Ref: "main", ETag: testEtag})
+	if err.Error() != "server error: 404" {
+		t.Fatal(err)
+	}
+}
+
+func TestBitbucketWithBadAuthentication(t *testing.T) {
+	as := makeBitbucketAPIServer(t, basicAuth(testUsername, testToken), "/2.0/repositories/testing/repo/refs/branches/main", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewBitbucketPoller(as.Client(), as.URL, testUsername, "anotherToken")
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "main", ETag: testEtag})
+	if err.Error() != "server error: 401" {
+		t.Fatal(err)
+	}
+}
+
+// With no auth-token, no auth header should be sent.
+func TestBitbucketWithNoAuthentication(t *testing.T) {
+	as := makeBitbucketAPIServer(t, nil, "/2.0/repositories/testing/repo/refs/branches/main", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewBitbucketPoller(as.Client(), as.URL, "", "")
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "main", ETag: testEtag})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// makeBitbucketAPIServer is used during testing to create an HTTP server to
+// return fixtures if the request matches.
+//
+// If authorized is nil, then requests must have no Authorization header.
+func makeBitbucketAPIServer(t *testing.T, authorized func(*http.Request) bool, wantPath, etag string, response []byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != wantPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if authorized != nil && !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" && authorized == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if etag == r.Header.Get("If-None-Match") {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(response); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+}
+
+func bearerAuth(token string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer "+token
+	}
+}
+
+func basicAuth(username, password string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		u, p, ok := r.BasicAuth()
+		return ok && u == username && p == password
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+// BitbucketServerPoller polls Bitbucket Server and Data Center.
+//
+// The repo is expected to be in the form "PROJECT-KEY/repo-slug".
+//
+// If a username is provided, requests are authenticated with basic auth,
+// otherwise the token is sent as a bearer token (HTTP access tokens).
+type BitbucketServerPoller struct {
+	client    *http.Client
+	endpoint  string
+	username  string
+	authToken string
+}
+
+// NewBitbucketServerPoller creates and returns a new Bitbucket Server poller.
+func NewBitbucketServerPoller(c *http.Client, endpoint, username, authToken string) *BitbucketServerPoller {
+	return &BitbucketServerPoller{client: c, endpoint: endpoint, username: username, authToken: authToken}
+}
+
+func (g BitbucketServerPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	requestURL, err := makeBitbucketServerURL(g.endpoint, repo, pr.Ref)
+	if err != nil {
+		logger.Error(err, "polling Bitbucket Server repo")
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	logger.Info("polling Bitbucket Server repo", "url", requestURL)
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to create request for Bitbucket Server: %w", err)
+	}
+	if pr.ETag != "" {
+		req.Header.Add("If-None-Match", pr.ETag)
+	}
+	req.Header.Add("Accept", "application/json")
+	switch {
+	case g.username != "":
+		req.SetBasicAuth(g.username, g.authToken)
+	case g.authToken != "":
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", g.authToken))
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "polling Bitbucket Server repo")
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("failed to get current commit: %v", err))
+	}
+	logger.Info("polled Bitbucket Server repo", "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return pr, nil, nil
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from Bitbucket Server")
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("reading body from Bitbucket Server: %w", err))
+	}
+	var page bitbucketServerCommits
+	err = json.Unmarshal(body, &page)
+	if err != nil {
+		logger.Error(err, "unmarshalling Bitbucket Server response")
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	if len(page.Values) == 0 {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrNotFound, fmt.Errorf("no commits found for ref %q", pr.Ref))
+	}
+	commit := page.Values[0]
+	sha, ok := commit["id"].(string)
+	if !ok {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("no commit SHA found for ref %q", pr.Ref))
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha, ETag: resp.Header.Get("ETag")}, commit, nil
+}
+
+// bitbucketServerCommits is a page of results from the commits endpoint.
+type bitbucketServerCommits struct {
+	Values []Commit `json:"values"`
+}
+
+func makeBitbucketServerURL(endpoint, repo, ref string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	project, slug, ok := strings.Cut(repo, "/")
+	if !ok {
+		return "", fmt.Errorf("invalid repository %q, expected PROJECT/repo", repo)
+	}
+	parsed.Path = path.Join(parsed.Path, "rest/api/1.0/projects", project, "repos", slug, "commits")
+	parsed.RawQuery = url.Values{
+		"until": []string{ref},
+		"limit": []string{"1"},
+	}.Encode()
+	return parsed.String(), nil
+}
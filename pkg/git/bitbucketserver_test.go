@@ -0,0 +1,151 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+)
+
+var _ CommitPoller = (*BitbucketServerPoller)(nil)
+
+func TestMakeBitbucketServerURL(t *testing.T) {
+	urlTests := []struct {
+		endpoint string
+		repo     string
+		ref      string
+		want     string
+	}{
+		{"https://bitbucket.example.com", "PRJ/repo", "main", "https://bitbucket.example.com/rest/api/1.0/projects/PRJ/repos/repo/commits?limit=1&until=main"},
+		{"https://example.com/bitbucket", "~USER/repo", "refs/heads/main", "https://example.com/bitbucket/rest/api/1.0/projects/~USER/repos/repo/commits?limit=1&until=refs%2Fheads%2Fmain"},
+	}
+
+	for _, tt := range urlTests {
+		got, err := makeBitbucketServerURL(tt.endpoint, tt.repo, tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("makeBitbucketServerURL(%q, %q, %q) got %q, want %q", tt.endpoint, tt.repo, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestBitbucketServerWithUnknownETag(t *testing.T) {
+	as := makeBitbucketServerAPIServer(t, bearerAuth(testToken), "/rest/api/1.0/projects/PRJ/repos/repo/commits", "main", mustReadFile(t, "testdata/bitbucketserver_commits.json"))
+	t.Cleanup(as.Close)
+	g := NewBitbucketServerPoller(as.Client(), as.URL, "", testToken)
+
+	polled, body, err := g.Poll(context.TODO(), "PRJ/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.SHA != "def0123abcdef4567abcdef8987abcdef6543abc" {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, "def0123abcdef4567abcdef8987abcdef6543abc")
+	}
+	if m := body["message"].(string); m != "More work on feature 1" {
+		t.Fatalf("got message %q", m)
+	}
+}
+
+func TestBitbucketServerWithBasicAuthentication(t *testing.T) {
+	as := makeBitbucketServerAPIServer(t, basicAuth(testUsername, testToken), "/rest/api/1.0/projects/PRJ/repos/repo/commits", "main", mustReadFile(t, "testdata/bitbucketserver_commits.json"))
+	t.Cleanup(as.Close)
+	g := NewBitbucketServerPoller(as.Client(), as.URL, testUsername, testToken)
+
+	polled, _, err := g.Poll(context.TODO(), "PRJ/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.SHA != "def0123abcdef4567abcdef8987abcdef6543abc" {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, "def0123abcdef4567abcdef8987abcdef6543abc")
+	}
+}
+
+func TestBitbucketServerWithNoCommits(t *testing.T) {
+	as := makeBitbucketServerAPIServer(t, bearerAuth(testToken), "/rest/api/1.0/projects/PRJ/repos/repo/commits", "main", []byte(`{"values":[]}`))
+	t.Cleanup(as.Close)
+	g := NewBitbucketServerPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "PRJ/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != `no commits found for ref "main"` {
+		t.Fatal(err)
+	}
+}
+
+func TestBitbucketServerWithMalformedCommit(t *testing.T) {
+	as := makeBitbucketServerAPIServer(t, bearerAuth(testToken), "/rest/api/1.0/projects/PRJ/repos/repo/commits", "main", []byte(`{"values":[{}]}`))
+	t.Cleanup(as.Close)
+	g := NewBitbucketServerPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "PRJ/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if !errors.Is(err, ErrMalformedResponse) {
+		t.Fatalf("got %v, want ErrMalformedResponse", err)
+	}
+}
+
+func TestBitbucketServerWithNotFoundResponse(t *testing.T) {
+	as := makeBitbucketServerAPIServer(t, bearerAuth(testToken), "/rest/api/1.0/projects/PRJ/repos/repo/commits", "main", nil)
+	t.Cleanup(as.Close)
+	g := NewBitbucketServerPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "PRJ/testing", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != "server error: 404" {
+		t.Fatal(err)
+	}
+}
+
+func TestBitbucketServerWithBadAuthentication(t *testing.T) {
+	as := makeBitbucketServerAPIServer(t, bearerAuth(testToken), "/rest/api/1.0/projects/PRJ/repos/repo/commits", "main", nil)
+	t.Cleanup(as.Close)
+	g := NewBitbucketServerPoller(as.Client(), as.URL, "", "anotherToken")
+
+	_, _, err := g.Poll(context.TODO(), "PRJ/repo", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != "server error: 401" {
+		t.Fatal(err)
+	}
+}
+
+// makeBitbucketServerAPIServer is used during testing to create an HTTP server
+// to return fixtures if the request matches.
+func makeBitbucketServerAPIServer(t *testing.T, authorized func(*http.Request) bool, wantPath, wantRef string, response []byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != wantPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if until := r.URL.Query().Get("until"); until != wantRef {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(response); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+}
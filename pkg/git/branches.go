@@ -0,0 +1,72 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+const (
+	// branchPrefix is the prefix of the refs for branches.
+	branchPrefix = "refs/heads/"
+	// maxBranchPages limits the number of pages of branches that are listed.
+	maxBranchPages = 10
+)
+
+// Branch is a branch in a repository.
+type Branch struct {
+	Name string
+	SHA  string
+}
+
+// Branches are the branches listed from a repository.
+type Branches struct {
+	Items []Branch
+	// Truncated is true if listing stopped at maxBranchPages, and there are
+	// more branches than the Items.
+	Truncated bool
+}
+
+// BranchLister implementations can list the branches in a repository.
+type BranchLister interface {
+	ListBranches(ctx context.Context, repo string) (Branches, error)
+}
+
+// branchesFromRefs returns the branches from the advertised refs.
+func branchesFromRefs(refs []remoteRef) []Branch {
+	var branches []Branch
+	for _, ref := range refs {
+		if name, ok := strings.CutPrefix(ref.Name, branchPrefix); ok {
+			branches = append(branches, Branch{Name: name, SHA: ref.SHA})
+		}
+	}
+	return branches
+}
+
+var linkNextRE = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPageURL returns the URL of the next page from the Link header, and an
+// empty string if this is the last page.
+func nextPageURL(resp *http.Response) string {
+	if m := linkNextRE.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+		return m[1]
+	}
+	return ""
+}
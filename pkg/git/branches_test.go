@@ -0,0 +1,54 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"net/http"
+	"testing"
+)
+
+var (
+	_ BranchLister = (*GitHubPoller)(nil)
+	_ BranchLister = (*GitLabPoller)(nil)
+	_ BranchLister = (*SmartHTTPPoller)(nil)
+	_ BranchLister = (*SSHPoller)(nil)
+)
+
+func TestNextPageURL(t *testing.T) {
+	linkTests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"no link", "", ""},
+		{"next and last", `<https://api.github.com/repositories/1/branches?page=2>; rel="next", <https://api.github.com/repositories/1/branches?page=5>; rel="last"`, "https://api.github.com/repositories/1/branches?page=2"},
+		{"last page", `<https://api.github.com/repositories/1/branches?page=4>; rel="prev", <https://api.github.com/repositories/1/branches?page=1>; rel="first"`, ""},
+	}
+
+	for _, tt := range linkTests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.link != "" {
+				resp.Header.Set("Link", tt.link)
+			}
+
+			if got := nextPageURL(resp); got != tt.want {
+				t.Errorf("nextPageURL() got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
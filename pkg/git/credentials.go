@@ -0,0 +1,33 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+// Credentials are used to authenticate requests to the upstream server.
+type Credentials struct {
+	// Username is optional, when provided, pollers that support it will use
+	// basic authentication with the Username and Token.
+	Username string
+	// Token is the access token, or the password when there is a Username.
+	Token string
+	// TokenSource provides short-lived access tokens e.g. for a GitHub App
+	// installation, when this is provided, the Token is not used.
+	TokenSource TokenSource
+	// PrivateKey is the PEM encoded SSH private key used for SSH URLs.
+	PrivateKey []byte
+	// KnownHosts are the known_hosts entries used to verify the SSH server.
+	KnownHosts []byte
+}
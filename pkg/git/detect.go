@@ -0,0 +1,257 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+const (
+	// maxProbeBodySize limits how much of a probe response is read.
+	maxProbeBodySize = 64 * 1024
+	// gitFallbackTTL is how long a host that wasn't detected uses the git
+	// type before it's probed again, in case the service was unavailable.
+	gitFallbackTTL = 10 * time.Minute
+)
+
+// DefaultProviderHosts are the hosts of the hosted services.
+var DefaultProviderHosts = map[string]pollingv1alpha1.RepoType{
+	"github.com":    pollingv1alpha1.GitHub,
+	"gitlab.com":    pollingv1alpha1.GitLab,
+	"gitea.com":     pollingv1alpha1.Gitea,
+	"codeberg.org":  pollingv1alpha1.Gitea,
+	"bitbucket.org": pollingv1alpha1.Bitbucket,
+	"dev.azure.com": pollingv1alpha1.AzureDevOps,
+}
+
+// repoTypes are the types that can be configured for a host.
+var repoTypes = []pollingv1alpha1.RepoType{
+	pollingv1alpha1.GitHub,
+	pollingv1alpha1.GitLab,
+	pollingv1alpha1.Gitea,
+	pollingv1alpha1.Bitbucket,
+	pollingv1alpha1.BitbucketServer,
+	pollingv1alpha1.AzureDevOps,
+	pollingv1alpha1.Gerrit,
+	pollingv1alpha1.Git,
+}
+
+// providerProbe identifies a self-hosted service from the response to a
+// well-known API endpoint.
+type providerProbe struct {
+	path     string
+	repoType pollingv1alpha1.RepoType
+	matches  func(status int, body map[string]any) bool
+}
+
+var providerProbes = []providerProbe{
+	{
+		path:     "/api/v3/meta",
+		repoType: pollingv1alpha1.GitHub,
+		matches: func(status int, body map[string]any) bool {
+			_, ok := body["verifiable_password_authentication"]
+			return status == http.StatusOK && ok
+		},
+	},
+	{
+		path:     "/api/v4/version",
+		repoType: pollingv1alpha1.GitLab,
+		matches: func(status int, body map[string]any) bool {
+			// GitLab requires authentication for the version, but the
+			// response is still recognisable.
+			if status == http.StatusUnauthorized {
+				return body["message"] == "401 Unauthorized"
+			}
+			_, hasRevision := body["revision"]
+			return status == http.StatusOK && hasRevision
+		},
+	},
+	{
+		path:     "/api/v1/version",
+		repoType: pollingv1alpha1.Gitea,
+		matches: func(status int, body map[string]any) bool {
+			_, ok := body["version"]
+			return status == http.StatusOK && ok
+		},
+	},
+}
+
+// ProviderDetector detects the type of a repository from the URL.
+//
+// Hosts are checked against the known hosts, and then the well-known API
+// endpoints of self-hosted services are probed, if nothing matches, the
+// generic git type is used.
+//
+// The detected types are cached for each host, the fallback to the git type
+// is only cached for the gitFallbackTTL, and servers that respond to a probe
+// with an error aren't cached.
+type ProviderDetector struct {
+	client *http.Client
+	hosts  map[string]pollingv1alpha1.RepoType
+
+	mu     sync.Mutex
+	probed map[string]probedType
+
+	now func() time.Time
+}
+
+// probedType is the type that was detected by probing a host.
+type probedType struct {
+	repoType pollingv1alpha1.RepoType
+	// expires is zero if the type doesn't expire.
+	expires time.Time
+}
+
+// NewProviderDetector creates and returns a new ProviderDetector.
+//
+// The hosts are checked in addition to the DefaultProviderHosts.
+func NewProviderDetector(c *http.Client, hosts map[string]pollingv1alpha1.RepoType) *ProviderDetector {
+	known := maps.Clone(DefaultProviderHosts)
+	for host, repoType := range hosts {
+		known[strings.ToLower(host)] = repoType
+	}
+	return &ProviderDetector{client: c, hosts: known, probed: map[string]probedType{}, now: time.Now}
+}
+
+// Detect returns the type of the repository at repoURL.
+func (d *ProviderDetector) Detect(ctx context.Context, repoURL string) (pollingv1alpha1.RepoType, error) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse repo URL %s: %w", repoURL, err)
+	}
+	host := strings.ToLower(parsed.Host)
+	if repoType, ok := d.hosts[host]; ok {
+		return repoType, nil
+	}
+	switch {
+	case strings.HasSuffix(host, ".visualstudio.com"):
+		return pollingv1alpha1.AzureDevOps, nil
+	case strings.HasSuffix(host, ".ghe.com"):
+		return pollingv1alpha1.GitHub, nil
+	}
+
+	d.mu.Lock()
+	probed, ok := d.probed[host]
+	d.mu.Unlock()
+	if ok && (probed.expires.IsZero() || d.now().Before(probed.expires)) {
+		return probed.repoType, nil
+	}
+
+	repoType, err := d.probe(ctx, parsed.Scheme+"://"+parsed.Host)
+	if err != nil {
+		return "", err
+	}
+	probed = probedType{repoType: repoType}
+	if repoType == pollingv1alpha1.Git {
+		probed.expires = d.now().Add(gitFallbackTTL)
+	}
+	d.mu.Lock()
+	d.probed[host] = probed
+	d.mu.Unlock()
+
+	return repoType, nil
+}
+
+func (d *ProviderDetector) probe(ctx context.Context, base string) (pollingv1alpha1.RepoType, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", base)
+	for _, p := range providerProbes {
+		status, body, err := d.get(ctx, base+p.path)
+		if err != nil {
+			logger.Error(err, "probing for repository type", "path", p.path)
+			return "", fmt.Errorf("failed to detect the repository type: %w", err)
+		}
+		if p.matches(status, body) {
+			logger.Info("detected repository type", "type", p.repoType)
+			return p.repoType, nil
+		}
+		// The service may be unavailable e.g. during an upgrade, so this
+		// isn't evidence that it's not this type.
+		if status >= http.StatusInternalServerError {
+			err := &StatusError{StatusCode: status}
+			logger.Error(err, "probing for repository type", "path", p.path)
+			return "", fmt.Errorf("failed to detect the repository type: %w", err)
+		}
+	}
+	logger.Info("unable to detect the repository type, using git", "type", pollingv1alpha1.Git)
+	return pollingv1alpha1.Git, nil
+}
+
+// get makes an unauthenticated request, the credentials are not sent, as the
+// type of server is unknown.
+//
+// Responses that are not JSON objects are returned with an empty body.
+func (d *ProviderDetector) get(ctx context.Context, requestURL string) (int, map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodySize))
+	if err != nil {
+		return 0, nil, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(data, &body); err != nil {
+		return resp.StatusCode, nil, nil
+	}
+	return resp.StatusCode, body, nil
+}
+
+// ParseProviderHosts parses a list of host=type entries, separated by commas
+// or newlines, text after a # is ignored.
+//
+// e.g. "github.example.com=github,gitlab.example.com=gitlab"
+func ParseProviderHosts(s string) (map[string]pollingv1alpha1.RepoType, error) {
+	hosts := map[string]pollingv1alpha1.RepoType{}
+	for _, line := range strings.Split(s, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			host, repoType, ok := strings.Cut(entry, "=")
+			host, repoType = strings.TrimSpace(host), strings.TrimSpace(repoType)
+			if !ok || host == "" {
+				return nil, fmt.Errorf("invalid provider host %q, expected host=type", entry)
+			}
+			if !slices.Contains(repoTypes, pollingv1alpha1.RepoType(repoType)) {
+				return nil, fmt.Errorf("unknown repository type %q for host %q", repoType, host)
+			}
+			hosts[strings.ToLower(host)] = pollingv1alpha1.RepoType(repoType)
+		}
+	}
+	return hosts, nil
+}
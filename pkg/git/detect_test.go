@@ -0,0 +1,264 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestProviderDetectorWithKnownHosts(t *testing.T) {
+	d := NewProviderDetector(http.DefaultClient, map[string]pollingv1alpha1.RepoType{
+		"GitHub.Example.com": pollingv1alpha1.GitHub,
+	})
+
+	hostTests := []struct {
+		repoURL string
+		want    pollingv1alpha1.RepoType
+	}{
+		{"https://github.com/testing/repo.git", pollingv1alpha1.GitHub},
+		{"https://gitlab.com/group/subgroup/project", pollingv1alpha1.GitLab},
+		{"https://codeberg.org/testing/repo", pollingv1alpha1.Gitea},
+		{"https://bitbucket.org/workspace/repo.git", pollingv1alpha1.Bitbucket},
+		{"https://dev.azure.com/org/project/_git/repo", pollingv1alpha1.AzureDevOps},
+		{"https://org.visualstudio.com/project/_git/repo", pollingv1alpha1.AzureDevOps},
+		{"https://octocorp.ghe.com/testing/repo.git", pollingv1alpha1.GitHub},
+		{"https://github.example.com/testing/repo.git", pollingv1alpha1.GitHub},
+	}
+
+	for _, tt := range hostTests {
+		t.Run(tt.repoURL, func(t *testing.T) {
+			got, err := d.Detect(context.TODO(), tt.repoURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Detect() got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProviderDetectorWithProbes(t *testing.T) {
+	probeTests := []struct {
+		name      string
+		responses map[string]probeResponse
+		want      pollingv1alpha1.RepoType
+	}{
+		{
+			"GitHub Enterprise Server",
+			map[string]probeResponse{"/api/v3/meta": {http.StatusOK, `{"verifiable_password_authentication":false,"installed_version":"3.12.0"}`}},
+			pollingv1alpha1.GitHub,
+		},
+		{
+			"GitLab",
+			map[string]probeResponse{"/api/v4/version": {http.StatusOK, `{"version":"16.9.1","revision":"e4e7d9c1"}`}},
+			pollingv1alpha1.GitLab,
+		},
+		{
+			"GitLab without authentication",
+			map[string]probeResponse{"/api/v4/version": {http.StatusUnauthorized, `{"message":"401 Unauthorized"}`}},
+			pollingv1alpha1.GitLab,
+		},
+		{
+			"Gitea",
+			map[string]probeResponse{"/api/v1/version": {http.StatusOK, `{"version":"1.21.7"}`}},
+			pollingv1alpha1.Gitea,
+		},
+		{
+			"unknown server",
+			map[string]probeResponse{"/api/v1/version": {http.StatusOK, `<html></html>`}},
+			pollingv1alpha1.Git,
+		},
+	}
+
+	for _, tt := range probeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			as, requests := makeProbeServer(t, tt.responses)
+			t.Cleanup(as.Close)
+			d := NewProviderDetector(as.Client(), nil)
+
+			got, err := d.Detect(context.TODO(), as.URL+"/testing/repo.git")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != tt.want {
+				t.Errorf("Detect() got %q, want %q", got, tt.want)
+			}
+			if n := *requests; n == 0 {
+				t.Fatal("no probe requests were made")
+			}
+		})
+	}
+}
+
+func TestProviderDetectorCachesProbes(t *testing.T) {
+	as, requests := makeProbeServer(t, map[string]probeResponse{
+		"/api/v1/version": {http.StatusOK, `{"version":"1.21.7"}`},
+	})
+	t.Cleanup(as.Close)
+	d := NewProviderDetector(as.Client(), nil)
+
+	for range 2 {
+		got, err := d.Detect(context.TODO(), as.URL+"/testing/repo.git")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != pollingv1alpha1.Gitea {
+			t.Errorf("Detect() got %q, want %q", got, pollingv1alpha1.Gitea)
+		}
+	}
+
+	// The GitHub, GitLab and Gitea endpoints are probed once.
+	if *requests != 3 {
+		t.Errorf("got %d requests, want 3", *requests)
+	}
+}
+
+func TestProviderDetectorWithServerError(t *testing.T) {
+	responses := map[string]probeResponse{
+		"/api/v3/meta": {http.StatusServiceUnavailable, `<html>Down for maintenance</html>`},
+	}
+	as, _ := makeProbeServer(t, responses)
+	t.Cleanup(as.Close)
+	d := NewProviderDetector(as.Client(), nil)
+
+	_, err := d.Detect(context.TODO(), as.URL+"/testing/repo.git")
+	utils.AssertErrorMatch(t, "failed to detect the repository type: server error: 503", err)
+	if !errors.Is(err, ErrServerError) {
+		t.Errorf("got %v, want ErrServerError", err)
+	}
+
+	// The failure isn't cached, the host is probed again.
+	delete(responses, "/api/v3/meta")
+	responses["/api/v1/version"] = probeResponse{http.StatusOK, `{"version":"1.21.7"}`}
+	got, err := d.Detect(context.TODO(), as.URL+"/testing/repo.git")
+	utils.AssertNoError(t, err)
+	if got != pollingv1alpha1.Gitea {
+		t.Errorf("Detect() got %q, want %q", got, pollingv1alpha1.Gitea)
+	}
+}
+
+func TestProviderDetectorExpiresGitFallback(t *testing.T) {
+	responses := map[string]probeResponse{}
+	as, requests := makeProbeServer(t, responses)
+	t.Cleanup(as.Close)
+	d := NewProviderDetector(as.Client(), nil)
+	now := time.Date(2024, time.March, 12, 9, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	got, err := d.Detect(context.TODO(), as.URL+"/testing/repo.git")
+	utils.AssertNoError(t, err)
+	if got != pollingv1alpha1.Git {
+		t.Errorf("Detect() got %q, want %q", got, pollingv1alpha1.Git)
+	}
+
+	// The fallback is cached until it expires.
+	now = now.Add(gitFallbackTTL - time.Second)
+	_, err = d.Detect(context.TODO(), as.URL+"/testing/repo.git")
+	utils.AssertNoError(t, err)
+	if *requests != 3 {
+		t.Errorf("got %d requests, want 3", *requests)
+	}
+
+	responses["/api/v1/version"] = probeResponse{http.StatusOK, `{"version":"1.21.7"}`}
+	now = now.Add(time.Second)
+	got, err = d.Detect(context.TODO(), as.URL+"/testing/repo.git")
+	utils.AssertNoError(t, err)
+	if got != pollingv1alpha1.Gitea {
+		t.Errorf("Detect() got %q, want %q", got, pollingv1alpha1.Gitea)
+	}
+}
+
+func TestProviderDetectorWithFailingServer(t *testing.T) {
+	as := httptest.NewTLSServer(http.NotFoundHandler())
+	as.Close()
+	d := NewProviderDetector(as.Client(), nil)
+
+	_, err := d.Detect(context.TODO(), as.URL+"/testing/repo.git")
+	utils.AssertErrorMatch(t, "failed to detect the repository type", err)
+}
+
+func TestParseProviderHosts(t *testing.T) {
+	hosts, err := ParseProviderHosts("github.example.com=github, gitlab.example.com = gitlab\n# Gerrit\nreview.example.com=gerrit # code review\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]pollingv1alpha1.RepoType{
+		"github.example.com": pollingv1alpha1.GitHub,
+		"gitlab.example.com": pollingv1alpha1.GitLab,
+		"review.example.com": pollingv1alpha1.Gerrit,
+	}
+	if diff := cmp.Diff(want, hosts); diff != "" {
+		t.Errorf("failed to parse hosts:\n%s", diff)
+	}
+}
+
+func TestParseProviderHosts_errors(t *testing.T) {
+	parseTests := []struct {
+		s       string
+		wantErr string
+	}{
+		{"github.example.com", `invalid provider host "github.example.com", expected host=type`},
+		{"=github", `invalid provider host "=github", expected host=type`},
+		{"svn.example.com=svn", `unknown repository type "svn" for host "svn.example.com"`},
+	}
+
+	for _, tt := range parseTests {
+		_, err := ParseProviderHosts(tt.s)
+		utils.AssertErrorMatch(t, tt.wantErr, err)
+	}
+}
+
+type probeResponse struct {
+	status int
+	body   string
+}
+
+// makeProbeServer is used during testing to create an HTTP server that
+// responds to the probes, requests for other paths get a 404.
+//
+// The number of requests to the server is recorded.
+func makeProbeServer(t *testing.T, responses map[string]probeResponse) (*httptest.Server, *int) {
+	var requests int
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(response.status)
+		if _, err := w.Write([]byte(response.body)); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	})), &requests
+}
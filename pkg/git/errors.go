@@ -0,0 +1,115 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// These are the kinds of error returned by the pollers, use errors.Is to
+// check the kind of an error.
+var (
+	// ErrNotFound is returned when the repository or ref doesn't exist, some
+	// services also respond with a not found when the token is invalid.
+	ErrNotFound = errors.New("not found")
+	// ErrUnauthorized is returned when the credentials are missing or invalid.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden is returned when the credentials don't allow access to
+	// the repository.
+	ErrForbidden = errors.New("forbidden")
+	// ErrRateLimited is returned when the rate limit has been exceeded, the
+	// error is a RateLimitError with the time that the limit resets.
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServerError is returned when the server fails to handle the request.
+	ErrServerError = errors.New("server error")
+	// ErrTimeout is returned when the request times out.
+	ErrTimeout = errors.New("timeout")
+	// ErrMalformedResponse is returned when the response can't be parsed.
+	ErrMalformedResponse = errors.New("malformed response")
+)
+
+// StatusError is returned when the server responds with an error status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("server error: %d", e.StatusCode)
+}
+
+// Is returns true if the target is the kind of error for the status code.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		// Azure DevOps responds with a 203 and a sign-in page when the
+		// token is invalid.
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusNonAuthoritativeInfo
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// kindError associates a kind with an error without changing the message.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+func withKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+// checkResponse returns an error if the response has an error status, rate
+// limits are returned as a RateLimitError, and other errors as a StatusError.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	if err := checkRateLimit(resp); err != nil {
+		return err
+	}
+	return &StatusError{StatusCode: resp.StatusCode}
+}
+
+// requestError returns the wrapped error, with the ErrTimeout kind if the
+// request timed out.
+func requestError(err, wrapped error) error {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return withKind(ErrTimeout, wrapped)
+	}
+	return wrapped
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+)
+
+var errorKinds = []error{ErrNotFound, ErrUnauthorized, ErrForbidden, ErrRateLimited, ErrServerError, ErrTimeout, ErrMalformedResponse}
+
+func TestStatusError(t *testing.T) {
+	statusTests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNonAuthoritativeInfo, ErrUnauthorized},
+		{http.StatusBadRequest, nil},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusInternalServerError, ErrServerError},
+		{http.StatusBadGateway, ErrServerError},
+	}
+
+	for _, tt := range statusTests {
+		err := &StatusError{StatusCode: tt.status}
+		assertErrorKind(t, err, tt.want)
+	}
+}
+
+func TestPollerErrors(t *testing.T) {
+	errorTests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		makePoller func(c *http.Client, endpoint string) CommitPoller
+		wantErr    string
+		want       error
+	}{
+		{
+			"GitHub not found",
+			func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) },
+			func(c *http.Client, endpoint string) CommitPoller { return NewGitHubPoller(c, endpoint, testToken) },
+			"server error: 404",
+			ErrNotFound,
+		},
+		{
+			"GitLab unauthorized",
+			func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusUnauthorized) },
+			func(c *http.Client, endpoint string) CommitPoller { return NewGitLabPoller(c, endpoint, testToken) },
+			"server error: 401",
+			ErrUnauthorized,
+		},
+		{
+			"Gitea server error",
+			func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusServiceUnavailable) },
+			func(c *http.Client, endpoint string) CommitPoller { return NewGiteaPoller(c, endpoint, testToken) },
+			"server error: 503",
+			ErrServerError,
+		},
+		{
+			"Bitbucket malformed response",
+			func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`<html></html>`)) },
+			func(c *http.Client, endpoint string) CommitPoller {
+				return NewBitbucketPoller(c, endpoint, testUsername, testToken)
+			},
+			"failed to decode response body",
+			ErrMalformedResponse,
+		},
+		{
+			"Git smart HTTP malformed response",
+			func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(`<html></html>`)) },
+			func(c *http.Client, endpoint string) CommitPoller {
+				return NewSmartHTTPPoller(c, endpoint, testUsername, testToken)
+			},
+			"server does not support the smart HTTP protocol",
+			ErrMalformedResponse,
+		},
+		{
+			"GitHub timeout",
+			func(w http.ResponseWriter, r *http.Request) { time.Sleep(200 * time.Millisecond) },
+			func(c *http.Client, endpoint string) CommitPoller {
+				c.Timeout = 10 * time.Millisecond
+				return NewGitHubPoller(c, endpoint, testToken)
+			},
+			"failed to get current commit",
+			ErrTimeout,
+		},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			as := httptest.NewTLSServer(tt.handler)
+			t.Cleanup(as.Close)
+			g := tt.makePoller(as.Client(), as.URL)
+
+			_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+
+			if err == nil || !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			assertErrorKind(t, err, tt.want)
+		})
+	}
+}
+
+// assertErrorKind checks that the error is the wanted kind, and no other
+// kind.
+func assertErrorKind(t *testing.T, err, want error) {
+	t.Helper()
+	for _, kind := range errorKinds {
+		if got := errors.Is(err, kind); got != (kind == want) {
+			t.Errorf("errors.Is(%v, %v) got %v", err, kind, got)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+// gerritXSSIPrefix is prepended to all JSON responses from Gerrit to prevent
+// XSSI attacks.
+const gerritXSSIPrefix = ")]}'"
+
+// GerritPoller polls Gerrit servers.
+//
+// Refs are polled as branches, unless they are tags "refs/tags/v1.0.0" or
+// changes, "refs/changes/34/1234/2" polls patchset 2 of change 1234, and
+// "refs/changes/34/1234" polls the current patchset.
+//
+// If an auth token is provided, it's used as the HTTP password for the
+// username, and requests are made to the authenticated "/a/" endpoints.
+type GerritPoller struct {
+	client    *http.Client
+	endpoint  string
+	username  string
+	authToken string
+}
+
+// NewGerritPoller creates and returns a new Gerrit poller.
+func NewGerritPoller(c *http.Client, endpoint, username, authToken string) *GerritPoller {
+	return &GerritPoller{client: c, endpoint: endpoint, username: username, authToken: authToken}
+}
+
+func (g GerritPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	requestURL, shaKeys, err := makeGerritURL(g.endpoint, repo, pr.Ref, g.authToken != "")
+	if err != nil {
+		logger.Error(err, "polling Gerrit repo")
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	logger.Info("polling Gerrit repo", "url", requestURL)
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to create request for Gerrit: %w", err)
+	}
+	if pr.ETag != "" {
+		req.Header.Add("If-None-Match", pr.ETag)
+	}
+	req.Header.Add("Accept", "application/json")
+	if g.authToken != "" {
+		req.SetBasicAuth(g.username, g.authToken)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "polling Gerrit repo")
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("failed to get current commit: %v", err))
+	}
+	logger.Info("polled Gerrit repo", "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return pr, nil, nil
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from Gerrit")
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("reading body from Gerrit: %w", err))
+	}
+	var commit Commit
+	err = json.Unmarshal(bytes.TrimPrefix(body, []byte(gerritXSSIPrefix)), &commit)
+	if err != nil {
+		logger.Error(err, "unmarshalling Gerrit response")
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	sha := gerritCommitSHA(commit, shaKeys)
+	if sha == "" {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrNotFound, fmt.Errorf("no commits found for ref %q", pr.Ref))
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha, ETag: resp.Header.Get("ETag")}, commit, nil
+}
+
+// makeGerritURL returns the URL to query for the ref, and the keys in the
+// response that can have the SHA of the commit, in order of preference.
+func makeGerritURL(endpoint, repo, ref string, authenticated bool) (string, []string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", nil, err
+	}
+	if authenticated {
+		parsed = parsed.JoinPath("a")
+	}
+	project := url.PathEscape(repo)
+
+	if change, ok := strings.CutPrefix(ref, "refs/changes/"); ok {
+		// refs/changes/<last two digits>/<change number>[/<patchset>]
+		elements := strings.Split(change, "/")
+		if len(elements) < 2 || len(elements) > 3 {
+			return "", nil, fmt.Errorf("invalid change ref %q", ref)
+		}
+		changeID := url.PathEscape(repo + "~" + elements[1])
+		if len(elements) == 2 {
+			parsed = parsed.JoinPath("changes", changeID)
+			parsed.RawQuery = url.Values{"o": []string{"CURRENT_REVISION"}}.Encode()
+			return parsed.String(), []string{"current_revision"}, nil
+		}
+		return parsed.JoinPath("changes", changeID, "revisions", elements[2], "commit").String(), []string{"commit"}, nil
+	}
+
+	if tag, ok := strings.CutPrefix(ref, "refs/tags/"); ok {
+		// The revision of an annotated tag is the tag, and the object is the
+		// commit, lightweight tags have no object.
+		return parsed.JoinPath("projects", project, "tags", url.PathEscape(tag)).String(), []string{"object", "revision"}, nil
+	}
+
+	return parsed.JoinPath("projects", project, "branches", url.PathEscape(ref)).String(), []string{"revision"}, nil
+}
+
+func gerritCommitSHA(commit Commit, keys []string) string {
+	for _, key := range keys {
+		if sha, ok := commit[key].(string); ok && sha != "" {
+			return sha
+		}
+	}
+	return ""
+}
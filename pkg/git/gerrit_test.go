@@ -0,0 +1,201 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+)
+
+var _ CommitPoller = (*GerritPoller)(nil)
+
+func TestGerritWithUnknownETag(t *testing.T) {
+	as := makeGerritAPIServer(t, basicAuth(testUsername, testToken), "/a/projects/platform%2Fbuild/branches/main", testEtag, mustReadFile(t, "testdata/gerrit_branch.json"))
+	t.Cleanup(as.Close)
+	g := NewGerritPoller(as.Client(), as.URL, testUsername, testToken)
+
+	polled, body, err := g.Poll(context.TODO(), "platform/build", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.ETag != testEtag {
+		t.Errorf("Poll() ETag got %s, want %s", polled.ETag, testEtag)
+	}
+	if polled.SHA != "67ebf73496383c6777035e374d2d664009e2aa5c" {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, "67ebf73496383c6777035e374d2d664009e2aa5c")
+	}
+	if r := body["ref"].(string); r != "refs/heads/main" {
+		t.Fatalf("got ref %q", r)
+	}
+}
+
+func TestGerritWithKnownTag(t *testing.T) {
+	as := makeGerritAPIServer(t, basicAuth(testUsername, testToken), "/a/projects/platform%2Fbuild/branches/main", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewGerritPoller(as.Client(), as.URL, testUsername, testToken)
+
+	polled, body, err := g.Poll(context.TODO(), "platform/build", pollingv1alpha1.PollStatus{Ref: "main", ETag: testEtag})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.ETag != testEtag {
+		t.Fatalf("Poll() got %s, want %s", polled.ETag, testEtag)
+	}
+	if body != nil {
+		t.Fatalf("expected an empty body, got %#v\n", body)
+	}
+}
+
+func TestGerritWithRefs(t *testing.T) {
+	refTests := []struct {
+		ref      string
+		wantPath string
+		fixture  string
+		wantSHA  string
+	}{
+		{"refs/heads/stable/1.0", "/a/projects/platform%2Fbuild/branches/refs%2Fheads%2Fstable%2F1.0", "testdata/gerrit_branch.json", "67ebf73496383c6777035e374d2d664009e2aa5c"},
+		{"refs/tags/v1.0.0", "/a/projects/platform%2Fbuild/tags/v1.0.0", "testdata/gerrit_tag.json", "1624f5af8ae89148d1a3730df8c290413e3dcf30"},
+		{"refs/changes/34/1234", "/a/changes/platform%2Fbuild~1234", "testdata/gerrit_change.json", "27cc4558b5a3d3387dd11ee2df7a117e7e581822"},
+		{"refs/changes/34/1234/2", "/a/changes/platform%2Fbuild~1234/revisions/2/commit", "testdata/gerrit_commit.json", "674ac754f91e64a0efb8087e59a176484bd534d1"},
+	}
+
+	for _, tt := range refTests {
+		t.Run(tt.ref, func(t *testing.T) {
+			as := makeGerritAPIServer(t, basicAuth(testUsername, testToken), tt.wantPath, testEtag, mustReadFile(t, tt.fixture))
+			t.Cleanup(as.Close)
+			g := NewGerritPoller(as.Client(), as.URL, testUsername, testToken)
+
+			polled, _, err := g.Poll(context.TODO(), "platform/build", pollingv1alpha1.PollStatus{Ref: tt.ref})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if polled.SHA != tt.wantSHA {
+				t.Errorf("Poll() SHA got %s, want %s", polled.SHA, tt.wantSHA)
+			}
+		})
+	}
+}
+
+func TestGerritWithInvalidChangeRef(t *testing.T) {
+	g := NewGerritPoller(http.DefaultClient, "https://gerrit.example.com", "", "")
+
+	_, _, err := g.Poll(context.TODO(), "platform/build", pollingv1alpha1.PollStatus{Ref: "refs/changes/34"})
+	utils.AssertErrorMatch(t, `invalid change ref "refs/changes/34"`, err)
+}
+
+func TestGerritWithNotFoundResponse(t *testing.T) {
+	as := makeGerritAPIServer(t, basicAuth(testUsername, testToken), "/a/projects/platform%2Fbuild/branches/main", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewGerritPoller(as.Client(), as.URL, testUsername, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "platform/testing", pollingv1alpha1.PollStatus{Ref: "main", ETag: testEtag})
+	if err.Error() != "server error: 404" {
+		t.Fatal(err)
+	}
+}
+
+func TestGerritWithBadAuthentication(t *testing.T) {
+	as := makeGerritAPIServer(t, basicAuth(testUsername, testToken), "/a/projects/platform%2Fbuild/branches/main", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewGerritPoller(as.Client(), as.URL, testUsername, "anotherToken")
+
+	_, _, err := g.Poll(context.TODO(), "platform/build", pollingv1alpha1.PollStatus{Ref: "main", ETag: testEtag})
+	if err.Error() != "server error: 401" {
+		t.Fatal(err)
+	}
+}
+
+// With no auth-token, no auth header should be sent, and the anonymous
+// endpoints are used.
+func TestGerritWithNoAuthentication(t *testing.T) {
+	as := makeGerritAPIServer(t, nil, "/projects/platform%2Fbuild/branches/main", testEtag, mustReadFile(t, "testdata/gerrit_branch.json"))
+	t.Cleanup(as.Close)
+	g := NewGerritPoller(as.Client(), as.URL, "", "")
+
+	polled, _, err := g.Poll(context.TODO(), "platform/build", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.SHA != "67ebf73496383c6777035e374d2d664009e2aa5c" {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, "67ebf73496383c6777035e374d2d664009e2aa5c")
+	}
+}
+
+func TestMakeGerritURL(t *testing.T) {
+	urlTests := []struct {
+		endpoint      string
+		ref           string
+		authenticated bool
+		want          string
+	}{
+		{"https://gerrit.example.com", "main", false, "https://gerrit.example.com/projects/platform%2Fbuild/branches/main"},
+		{"https://gerrit.example.com", "main", true, "https://gerrit.example.com/a/projects/platform%2Fbuild/branches/main"},
+		{"https://example.com/r", "main", true, "https://example.com/r/a/projects/platform%2Fbuild/branches/main"},
+		{"https://example.com/r", "refs/changes/34/1234", false, "https://example.com/r/changes/platform%2Fbuild~1234?o=CURRENT_REVISION"},
+	}
+
+	for _, tt := range urlTests {
+		got, _, err := makeGerritURL(tt.endpoint, "platform/build", tt.ref, tt.authenticated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("makeGerritURL(%q, %q) got %q, want %q", tt.endpoint, tt.ref, got, tt.want)
+		}
+	}
+}
+
+// makeGerritAPIServer is used during testing to create an HTTP server to
+// return fixtures if the request matches.
+//
+// The wantPath is compared to the escaped path, as project names are encoded.
+//
+// If authorized is nil, then requests must have no Authorization header.
+func makeGerritAPIServer(t *testing.T, authorized func(*http.Request) bool, wantPath, etag string, response []byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != wantPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if authorized != nil && !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" && authorized == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if etag == r.Header.Get("If-None-Match") {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(response); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+}
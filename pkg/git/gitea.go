@@ -0,0 +1,118 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+// GiteaPoller polls Gitea and Forgejo servers.
+type GiteaPoller struct {
+	client    *http.Client
+	endpoint  string
+	authToken string
+}
+
+// NewGiteaPoller creates and returns a new Gitea poller.
+func NewGiteaPoller(c *http.Client, endpoint, authToken string) *GiteaPoller {
+	return &GiteaPoller{client: c, endpoint: endpoint, authToken: authToken}
+}
+
+func (g GiteaPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	requestURL, err := makeGiteaURL(g.endpoint, repo, pr.Ref)
+	if err != nil {
+		logger.Error(err, "polling Gitea repo")
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	logger.Info("polling Gitea repo", "url", requestURL)
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to create request for Gitea: %w", err)
+	}
+	// Gitea only honours If-None-Match on some endpoints, when it doesn't
+	// the response is a 200 and the SHA comparison will detect no change.
+	if pr.ETag != "" {
+		req.Header.Add("If-None-Match", pr.ETag)
+	}
+	req.Header.Add("Accept", "application/json")
+	if g.authToken != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", g.authToken))
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "polling Gitea repo")
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("failed to get current commit: %v", err))
+	}
+	logger.Info("polled Gitea repo", "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return pr, nil, nil
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from Gitea")
+		}
+	}()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("reading body from Gitea: %w", err))
+	}
+	var gc []map[string]interface{}
+	err = json.Unmarshal(body, &gc)
+	if err != nil {
+		logger.Error(err, "unmarshalling Gitea response")
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	if len(gc) == 0 {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrNotFound, fmt.Errorf("no commits found for ref %q", pr.Ref))
+	}
+	commit := gc[0]
+	sha, ok := commit["sha"].(string)
+	if !ok {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("no commit SHA found for ref %q", pr.Ref))
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha, ETag: resp.Header.Get("ETag")}, commit, nil
+}
+
+func makeGiteaURL(endpoint, repo, ref string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	parsed.Path = path.Join(parsed.Path, "api/v1/repos", repo, "commits")
+	parsed.RawQuery = url.Values{
+		"sha":          []string{ref},
+		"limit":        []string{"1"},
+		"stat":         []string{"false"},
+		"verification": []string{"false"},
+		"files":        []string{"false"},
+	}.Encode()
+	return parsed.String(), nil
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+)
+
+var _ CommitPoller = (*GiteaPoller)(nil)
+
+func TestNewGiteaPoller(t *testing.T) {
+	newTests := []struct {
+		endpoint     string
+		wantEndpoint string
+	}{
+		{"https://gitea.example.com", "https://gitea.example.com"},
+	}
+
+	for _, tt := range newTests {
+		c := NewGiteaPoller(http.DefaultClient, tt.endpoint, "testToken")
+		if c.endpoint != tt.wantEndpoint {
+			t.Errorf("%#v got %#v, want %#v", tt.endpoint, c.endpoint, tt.wantEndpoint)
+		}
+	}
+}
+
+func TestGiteaWithUnknownETag(t *testing.T) {
+	as := makeGiteaAPIServer(t, testToken, "/api/v1/repos/testing/repo/commits", "master", testEtag, mustReadFile(t, "testdata/gitea_commit.json"))
+	t.Cleanup(as.Close)
+	g := NewGiteaPoller(as.Client(), as.URL, testToken)
+
+	polled, body, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.ETag != testEtag {
+		t.Errorf("Poll() ETag got %s, want %s", polled.ETag, testEtag)
+	}
+	if polled.SHA != "3e5ac57b3b1c8a2e8e1b1d8b0f6c5bd4c9f0b0f1" {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, "3e5ac57b3b1c8a2e8e1b1d8b0f6c5bd4c9f0b0f1")
+	}
+	if m := body["html_url"].(string); m != "https://gitea.example.com/testing/repo/commit/3e5ac57b3b1c8a2e8e1b1d8b0f6c5bd4c9f0b0f1" {
+		t.Fatalf("got html_url %s", m)
+	}
+}
+
+func TestGiteaWithKnownTag(t *testing.T) {
+	as := makeGiteaAPIServer(t, testToken, "/api/v1/repos/testing/repo/commits", "master", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewGiteaPoller(as.Client(), as.URL, testToken)
+
+	polled, body, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master", ETag: testEtag})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.ETag != testEtag {
+		t.Fatalf("Poll() got %s, want %s", polled.ETag, testEtag)
+	}
+	if body != nil {
+		t.Fatalf("expected an empty body, got %#v\n", body)
+	}
+}
+
+func TestGiteaWithNoCommits(t *testing.T) {
+	as := makeGiteaAPIServer(t, testToken, "/api/v1/repos/testing/repo/commits", "master", testEtag, []byte(`[]`))
+	t.Cleanup(as.Close)
+	g := NewGiteaPoller(as.Client(), as.URL, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if err.Error() != `no commits found for ref "master"` {
+		t.Fatal(err)
+	}
+}
+
+func TestGiteaWithMalformedCommit(t *testing.T) {
+	as := makeGiteaAPIServer(t, testToken, "/api/v1/repos/testing/repo/commits", "master", testEtag, []byte(`[{}]`))
+	t.Cleanup(as.Close)
+	g := NewGiteaPoller(as.Client(), as.URL, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if !errors.Is(err, ErrMalformedResponse) {
+		t.Fatalf("got %v, want ErrMalformedResponse", err)
+	}
+}
+
+func TestGiteaWithNotFoundResponse(t *testing.T) {
+	as := makeGiteaAPIServer(t, testToken, "/api/v1/repos/testing/repo/commits", "master", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewGiteaPoller(as.Client(), as.URL, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/testing", pollingv1alpha1.PollStatus{Ref: "master", ETag: testEtag})
+	if err.Error() != "server error: 404" {
+		t.Fatal(err)
+	}
+}
+
+func TestGiteaWithBadAuthentication(t *testing.T) {
+	as := makeGiteaAPIServer(t, testToken, "/api/v1/repos/testing/repo/commits", "master", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewGiteaPoller(as.Client(), as.URL, "anotherToken")
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master", ETag: testEtag})
+	if err.Error() != "server error: 401" {
+		t.Fatal(err)
+	}
+}
+
+// With no auth-token, no auth header should be sent.
+func TestGiteaWithNoAuthentication(t *testing.T) {
+	as := makeGiteaAPIServer(t, "", "/api/v1/repos/testing/repo/commits", "master", testEtag, nil)
+	t.Cleanup(as.Close)
+	g := NewGiteaPoller(as.Client(), as.URL, "")
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master", ETag: testEtag})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// makeGiteaAPIServer is used during testing to create an HTTP server to return
+// fixtures if the request matches.
+func makeGiteaAPIServer(t *testing.T, authToken, wantPath, wantRef, etag string, response []byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != wantPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if queryRef := r.URL.Query().Get("sha"); queryRef != wantRef {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		if limit := r.URL.Query().Get("limit"); limit != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if authToken != "" {
+			if auth := r.Header.Get("Authorization"); auth != fmt.Sprintf("token %s", authToken) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		if auth := r.Header.Get("Authorization"); auth != "" && authToken == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if etag == r.Header.Get("If-None-Match") {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(response); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package git
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -23,6 +24,9 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
 	"github.com/go-logr/logr"
@@ -31,9 +35,9 @@ import (
 // TODO: add logging - especially of the response body.
 
 type GitHubPoller struct {
-	client    *http.Client
-	endpoint  string
-	authToken string
+	client   *http.Client
+	endpoint string
+	tokens   TokenSource
 }
 
 const (
@@ -42,7 +46,13 @@ const (
 
 // NewGitHubPoller creates and returns a new GitHub poller.
 func NewGitHubPoller(c *http.Client, endpoint, authToken string) *GitHubPoller {
-	return &GitHubPoller{client: c, endpoint: endpoint, authToken: authToken}
+	return NewGitHubPollerWithTokenSource(c, endpoint, StaticToken(authToken))
+}
+
+// NewGitHubPollerWithTokenSource creates and returns a new GitHub poller that
+// gets the token for each request from the TokenSource.
+func NewGitHubPollerWithTokenSource(c *http.Client, endpoint string, tokens TokenSource) *GitHubPoller {
+	return &GitHubPoller{client: c, endpoint: endpoint, tokens: tokens}
 }
 
 func (g GitHubPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
@@ -62,19 +72,22 @@ func (g GitHubPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.
 		req.Header.Add("If-None-Match", pr.ETag)
 	}
 	req.Header.Add("Accept", chitauriPreview)
-	if g.authToken != "" {
-		req.Header.Add("Authorization", fmt.Sprintf("token %s", g.authToken))
+	authToken, err := g.tokens.Token(ctx)
+	if err != nil {
+		logger.Error(err, "getting the GitHub token")
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to get the token: %w", err)
+	}
+	if authToken != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", authToken))
 	}
 	resp, err := g.client.Do(req)
 	if err != nil {
 		logger.Error(err, "polling GitHub repo")
-		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to get current commit: %v", err)
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("failed to get current commit: %v", err))
 	}
-	// TODO: Return an error type that we can identify as a NotFound, likely
-	// this is either a security token issue, or an unknown repo.
 	logger.Info("polled GitHub repo", "status", resp.StatusCode)
-	if resp.StatusCode >= http.StatusBadRequest {
-		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("server error: %d", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
 	}
 	if resp.StatusCode == http.StatusNotModified {
 		return pr, nil, nil
@@ -87,16 +100,442 @@ func (g GitHubPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("reading body from GitHub: %w", err)
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("reading body from GitHub: %w", err))
 	}
 	var gc map[string]interface{}
 	err = json.Unmarshal(body, &gc)
 	if err != nil {
 		logger.Error(err, "unmarshalling GitHub response")
-		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to decode response body: %w", err)
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	sha, ok := gc["sha"].(string)
+	if !ok {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("no commit SHA found for ref %q", pr.Ref))
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha, ETag: resp.Header.Get("ETag")}, gc, nil
+}
+
+type githubBranch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+}
+
+// ListBranches lists the branches in the repository, this follows the
+// pagination up to maxBranchPages, and the branches are Truncated if there are
+// more pages.
+func (g GitHubPoller) ListBranches(ctx context.Context, repo string) (Branches, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	parsed, err := url.Parse(g.endpoint)
+	if err != nil {
+		return Branches{}, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	parsed.Path = path.Join(parsed.Path, "repos", repo, "branches")
+	parsed.RawQuery = url.Values{"per_page": []string{"100"}}.Encode()
+	authToken, err := g.tokens.Token(ctx)
+	if err != nil {
+		logger.Error(err, "getting the GitHub token")
+		return Branches{}, fmt.Errorf("failed to get the token: %w", err)
+	}
+
+	var branches []Branch
+	requestURL := parsed.String()
+	for range maxBranchPages {
+		logger.Info("listing GitHub branches", "url", requestURL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return Branches{}, fmt.Errorf("failed to create request for GitHub: %w", err)
+		}
+		if authToken != "" {
+			req.Header.Add("Authorization", fmt.Sprintf("token %s", authToken))
+		}
+		resp, err := g.client.Do(req)
+		if err != nil {
+			logger.Error(err, "listing GitHub branches")
+			return Branches{}, requestError(err, fmt.Errorf("failed to list branches: %v", err))
+		}
+		body, readErr := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from GitHub")
+		}
+		logger.Info("listed GitHub branches", "status", resp.StatusCode)
+		if err := checkResponse(resp); err != nil {
+			return Branches{}, err
+		}
+		if readErr != nil {
+			return Branches{}, requestError(readErr, fmt.Errorf("reading body from GitHub: %w", readErr))
+		}
+		var gb []githubBranch
+		if err := json.Unmarshal(body, &gb); err != nil {
+			logger.Error(err, "unmarshalling GitHub response")
+			return Branches{}, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+		}
+		for _, b := range gb {
+			branches = append(branches, Branch{Name: b.Name, SHA: b.Commit.SHA})
+		}
+		if requestURL = nextPageURL(resp); requestURL == "" {
+			return Branches{Items: branches}, nil
+		}
+	}
+	logger.Info("listed the maximum number of branch pages", "branches", len(branches))
+
+	return Branches{Items: branches, Truncated: true}, nil
+}
+
+// githubTagsQuery lists the tags ordered by the commit date, annotated tags
+// point to the tagged commit.
+const githubTagsQuery = `query($owner: String!, $name: String!, $after: String) {
+  repository(owner: $owner, name: $name) {
+    refs(refPrefix: "refs/tags/", first: 100, after: $after, orderBy: {field: TAG_COMMIT_DATE, direction: DESC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        name
+        target {
+          oid
+          ... on Commit { committedDate }
+          ... on Tag { tagger { date } target { oid ... on Commit { committedDate } } }
+        }
+      }
+    }
+  }
+}`
+
+type githubTagsResponse struct {
+	Data struct {
+		Repository *struct {
+			Refs struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []struct {
+					Name   string          `json:"name"`
+					Target githubTagTarget `json:"target"`
+				} `json:"nodes"`
+			} `json:"refs"`
+		} `json:"repository"`
+	} `json:"data"`
+	Errors []githubGraphQLError `json:"errors"`
+}
+
+type githubTagTarget struct {
+	OID           string    `json:"oid"`
+	CommittedDate time.Time `json:"committedDate"`
+	Tagger        *struct {
+		Date time.Time `json:"date"`
+	} `json:"tagger"`
+	Target *githubTagTarget `json:"target"`
+}
+
+// ListTags lists the tags in the repository, this follows the pagination up
+// to maxTagPages.
+//
+// The tags are listed with the GraphQL API because the REST API doesn't
+// provide the dates, GitHub requires a token for the GraphQL API.
+func (g GitHubPoller) ListTags(ctx context.Context, repo string) ([]Tag, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid GitHub repository %q", repo)
+	}
+	requestURL, err := githubGraphQLURL(g.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	authToken, err := g.tokens.Token(ctx)
+	if err != nil {
+		logger.Error(err, "getting the GitHub token")
+		return nil, fmt.Errorf("failed to get the token: %w", err)
+	}
+
+	var tags []Tag
+	variables := map[string]string{"owner": owner, "name": name}
+	for range maxTagPages {
+		body, err := json.Marshal(githubGraphQLRequest{Query: githubTagsQuery, Variables: variables})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the query: %w", err)
+		}
+		logger.Info("listing GitHub tags", "url", requestURL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request for GitHub: %w", err)
+		}
+		req.Header.Add("Content-Type", "application/json")
+		if authToken != "" {
+			req.Header.Add("Authorization", fmt.Sprintf("bearer %s", authToken))
+		}
+		resp, err := g.client.Do(req)
+		if err != nil {
+			logger.Error(err, "listing GitHub tags")
+			return nil, requestError(err, fmt.Errorf("failed to list tags: %v", err))
+		}
+		data, readErr := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from GitHub")
+		}
+		logger.Info("listed GitHub tags", "status", resp.StatusCode)
+		if err := checkResponse(resp); err != nil {
+			return nil, err
+		}
+		if readErr != nil {
+			return nil, requestError(readErr, fmt.Errorf("reading body from GitHub: %w", readErr))
+		}
+		var response githubTagsResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			logger.Error(err, "unmarshalling GitHub response")
+			return nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+		}
+		if len(response.Errors) > 0 {
+			return nil, githubGraphQLErr(response.Errors[0])
+		}
+		if response.Data.Repository == nil {
+			return nil, &StatusError{StatusCode: http.StatusNotFound}
+		}
+
+		refs := response.Data.Repository.Refs
+		for _, node := range refs.Nodes {
+			tags = append(tags, githubTag(node.Name, node.Target))
+		}
+		if !refs.PageInfo.HasNextPage {
+			return tags, nil
+		}
+		variables["after"] = refs.PageInfo.EndCursor
+	}
+	logger.Info("listed the maximum number of tag pages", "tags", len(tags))
+
+	return tags, nil
+}
+
+func githubTag(name string, target githubTagTarget) Tag {
+	if target.Target == nil {
+		return Tag{Name: name, SHA: target.OID, Created: target.CommittedDate}
+	}
+	created := target.Target.CommittedDate
+	if target.Tagger != nil {
+		created = target.Tagger.Date
+	}
+	return Tag{Name: name, SHA: target.Target.OID, Created: created}
+}
+
+type githubPullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+	State   string `json:"state"`
+	Merged  bool   `json:"merged"`
+	// MergedAt is only set for merged pull requests in the list response.
+	MergedAt *time.Time `json:"merged_at"`
+	Head     struct {
+		Ref string `json:"ref"`
+		SHA string `json:"sha"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+func (p githubPullRequest) pullRequest() PullRequest {
+	state := p.State
+	if p.Merged || p.MergedAt != nil {
+		state = PullRequestMerged
+	}
+	var labels []string
+	for _, label := range p.Labels {
+		labels = append(labels, label.Name)
+	}
+	return PullRequest{
+		Number:  p.Number,
+		Title:   p.Title,
+		URL:     p.HTMLURL,
+		State:   state,
+		HeadRef: p.Head.Ref,
+		HeadSHA: p.Head.SHA,
+		BaseRef: p.Base.Ref,
+		Labels:  labels,
+	}
+}
+
+// ListPullRequests lists the open pull requests in the repository, this
+// follows the pagination up to maxPullRequestPages.
+func (g GitHubPoller) ListPullRequests(ctx context.Context, repo string) ([]PullRequest, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	parsed, err := url.Parse(g.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	parsed.Path = path.Join(parsed.Path, "repos", repo, "pulls")
+	parsed.RawQuery = url.Values{"state": []string{"open"}, "per_page": []string{"100"}}.Encode()
+
+	var pullRequests []PullRequest
+	requestURL := parsed.String()
+	for range maxPullRequestPages {
+		var gp []githubPullRequest
+		resp, err := g.getJSON(ctx, logger, "pull requests", requestURL, "", &gp)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range gp {
+			pullRequests = append(pullRequests, p.pullRequest())
+		}
+		if requestURL = nextPageURL(resp); requestURL == "" {
+			return pullRequests, nil
+		}
+	}
+	logger.Info("listed the maximum number of pull request pages", "pullRequests", len(pullRequests))
+
+	return pullRequests, nil
+}
+
+// GetPullRequest gets a pull request by number.
+func (g GitHubPoller) GetPullRequest(ctx context.Context, repo string, number int) (PullRequest, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	parsed, err := url.Parse(g.endpoint)
+	if err != nil {
+		return PullRequest{}, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	parsed.Path = path.Join(parsed.Path, "repos", repo, "pulls", strconv.Itoa(number))
+
+	var gp githubPullRequest
+	if _, err := g.getJSON(ctx, logger, "pull request", parsed.String(), "", &gp); err != nil {
+		return PullRequest{}, err
+	}
+	return gp.pullRequest(), nil
+}
+
+type githubRelease struct {
+	Name        string     `json:"name"`
+	TagName     string     `json:"tag_name"`
+	HTMLURL     string     `json:"html_url"`
+	Body        string     `json:"body"`
+	Draft       bool       `json:"draft"`
+	Prerelease  bool       `json:"prerelease"`
+	PublishedAt *time.Time `json:"published_at"`
+	Assets      []struct {
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+		ContentType        string `json:"content_type"`
+		Size               int64  `json:"size"`
+	} `json:"assets"`
+}
+
+// ListReleases lists the first page of releases in the repository, GitHub
+// lists the newest releases first.
+func (g GitHubPoller) ListReleases(ctx context.Context, repo, etag string) (Releases, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	parsed, err := url.Parse(g.endpoint)
+	if err != nil {
+		return Releases{}, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	parsed.Path = path.Join(parsed.Path, "repos", repo, "releases")
+	parsed.RawQuery = url.Values{"per_page": []string{"100"}}.Encode()
+
+	var gr []githubRelease
+	resp, err := g.getJSON(ctx, logger, "releases", parsed.String(), etag, &gr)
+	if err != nil {
+		return Releases{}, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return Releases{ETag: etag, NotModified: true}, nil
+	}
+	releases := Releases{ETag: resp.Header.Get("ETag")}
+	for _, r := range gr {
+		release := Release{
+			Name:       r.Name,
+			Tag:        r.TagName,
+			URL:        r.HTMLURL,
+			Body:       r.Body,
+			Draft:      r.Draft,
+			Prerelease: r.Prerelease,
+		}
+		if r.PublishedAt != nil {
+			release.Published = *r.PublishedAt
+		}
+		for _, a := range r.Assets {
+			release.Assets = append(release.Assets, ReleaseAsset{Name: a.Name, URL: a.BrowserDownloadURL, ContentType: a.ContentType, Size: a.Size})
+		}
+		releases.Items = append(releases.Items, release)
+	}
+	return releases, nil
+}
+
+// getJSON makes a GET request to the GitHub API and decodes the JSON body
+// into v.
+//
+// If the etag is not empty, it's sent in the If-None-Match header, and the
+// body of a 304 Not Modified response isn't decoded.
+func (g GitHubPoller) getJSON(ctx context.Context, logger logr.Logger, resource, requestURL, etag string, v any) (*http.Response, error) {
+	authToken, err := g.tokens.Token(ctx)
+	if err != nil {
+		logger.Error(err, "getting the GitHub token")
+		return nil, fmt.Errorf("failed to get the token: %w", err)
+	}
+	logger.Info("getting GitHub "+resource, "url", requestURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for GitHub: %w", err)
+	}
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+	if authToken != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("token %s", authToken))
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "getting GitHub "+resource)
+		return nil, requestError(err, fmt.Errorf("failed to get %s: %v", resource, err))
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	if err := resp.Body.Close(); err != nil {
+		logger.Error(err, "closing request body from GitHub")
+	}
+	logger.Info("got GitHub "+resource, "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	if readErr != nil {
+		return nil, requestError(readErr, fmt.Errorf("reading body from GitHub: %w", readErr))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		logger.Error(err, "unmarshalling GitHub response")
+		return nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
 	}
-	logger.Info("poll complete", "ref", pr.Ref, "sha", gc["sha"])
-	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: gc["sha"].(string), ETag: resp.Header.Get("ETag")}, gc, nil
+	return resp, nil
+}
+
+// GitHubAPIURL returns the API endpoint for the GitHub server that hosts the
+// repository URL.
+//
+// github.com uses api.github.com, GitHub Enterprise Cloud with data residency
+// (*.ghe.com) uses the api. subdomain, and everything else is assumed to be
+// GitHub Enterprise Server which serves the API from /api/v3.
+func GitHubAPIURL(repoURL string) (string, error) {
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", err
+	}
+	host := strings.ToLower(parsed.Host)
+	switch {
+	case host == "github.com" || host == "www.github.com":
+		return "https://api.github.com", nil
+	case host == "api.github.com":
+		return "https://" + host, nil
+	case strings.HasSuffix(host, ".ghe.com"):
+		if !strings.HasPrefix(host, "api.") {
+			host = "api." + host
+		}
+		return "https://" + host, nil
+	}
+
+	return (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/api/v3"}).String(), nil
 }
 
 func makeGitHubURL(endpoint, repo, ref string) (string, error) {
@@ -104,7 +543,7 @@ func makeGitHubURL(endpoint, repo, ref string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	parsed.Path = path.Join("repos", repo, "commits", ref)
+	parsed.Path = path.Join(parsed.Path, "repos", repo, "commits", ref)
 
 	return parsed.String(), nil
 }
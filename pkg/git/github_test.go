@@ -17,9 +17,17 @@ package git
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 
 	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
 	"github.com/gitops-tools/gitpoller-controller/test/utils"
@@ -101,6 +109,17 @@ func TestGitHubWithNotFoundResponse(t *testing.T) {
 	}
 }
 
+func TestGitHubWithMalformedCommit(t *testing.T) {
+	as := utils.MakeGitHubAPIServer(t, testToken, "/repos/testing/repo/commits/master", testEtag, []byte(`{"sha":null}`))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if !errors.Is(err, ErrMalformedResponse) {
+		t.Fatalf("got %v, want ErrMalformedResponse", err)
+	}
+}
+
 // It's impossible to distinguish between unknown repo, and bad auth token, both
 // respond with a 404.
 func TestGitHubWithBadAuthentication(t *testing.T) {
@@ -128,6 +147,338 @@ func TestGitHubWithNoAuthentication(t *testing.T) {
 	}
 }
 
+func TestGitHubWithEnterpriseServer(t *testing.T) {
+	as := utils.MakeGitHubAPIServer(t, testToken, "/api/v3/repos/testing/repo/commits/master", testEtag, mustReadFile(t, "testdata/github_commit.json"))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL+"/api/v3", testToken)
+
+	polled, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.ETag != testEtag {
+		t.Errorf("Poll() ETag got %s, want %s", polled.ETag, testEtag)
+	}
+}
+
+func TestMakeGitHubURL(t *testing.T) {
+	urlTests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://api.github.com", "https://api.github.com/repos/testing/repo/commits/main"},
+		{"https://github.example.com/api/v3", "https://github.example.com/api/v3/repos/testing/repo/commits/main"},
+		{"https://github.example.com/api/v3/", "https://github.example.com/api/v3/repos/testing/repo/commits/main"},
+	}
+
+	for _, tt := range urlTests {
+		got, err := makeGitHubURL(tt.endpoint, "testing/repo", "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("makeGitHubURL(%q) got %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubAPIURL(t *testing.T) {
+	urlTests := []struct {
+		repoURL string
+		want    string
+	}{
+		{"https://github.com/testing/repo.git", "https://api.github.com"},
+		{"https://GitHub.com/testing/repo", "https://api.github.com"},
+		{"https://octocorp.ghe.com/testing/repo.git", "https://api.octocorp.ghe.com"},
+		{"https://api.octocorp.ghe.com", "https://api.octocorp.ghe.com"},
+		{"https://git.corp.example/testing/repo.git", "https://git.corp.example/api/v3"},
+		{"http://git.corp.example:8080/testing/repo", "http://git.corp.example:8080/api/v3"},
+	}
+
+	for _, tt := range urlTests {
+		got, err := GitHubAPIURL(tt.repoURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("GitHubAPIURL(%q) got %q, want %q", tt.repoURL, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubListTags(t *testing.T) {
+	as := makeGitHubTagsServer(t, testToken, []string{
+		`{"data":{"repository":{"refs":{"pageInfo":{"hasNextPage":true,"endCursor":"cursor1"},"nodes":[{"name":"v1.1.0","target":{"oid":"7638417db6d59f3c431d3e1f261cc637155684cd","committedDate":"2024-03-01T10:00:00Z"}}]}}}}`,
+		`{"data":{"repository":{"refs":{"pageInfo":{"hasNextPage":false,"endCursor":"cursor2"},"nodes":[{"name":"v1.0.0","target":{"oid":"c0d0b33a5a3db0e4f4f6d6e3ea0e3c4c7b7e0a1f","tagger":{"date":"2024-02-01T10:00:00Z"},"target":{"oid":"24317a55785cd98d6c9bf50a5204bc6be17e7316","committedDate":"2024-01-01T10:00:00Z"}}}]}}}}`,
+	})
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	tags, err := g.ListTags(context.TODO(), "testing/repo")
+	utils.AssertNoError(t, err)
+
+	want := []Tag{
+		{Name: "v1.1.0", SHA: "7638417db6d59f3c431d3e1f261cc637155684cd", Created: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)},
+		{Name: "v1.0.0", SHA: "24317a55785cd98d6c9bf50a5204bc6be17e7316", Created: time.Date(2024, time.February, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(want, tags); diff != "" {
+		t.Errorf("ListTags() failed:\n%s", diff)
+	}
+}
+
+func TestGitHubListTagsWithNotFoundRepository(t *testing.T) {
+	as := makeGitHubTagsServer(t, testToken, []string{
+		`{"data":{"repository":null},"errors":[{"type":"NOT_FOUND","path":["repository"],"message":"Could not resolve to a Repository"}]}`,
+	})
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	_, err := g.ListTags(context.TODO(), "testing/repo")
+	utils.AssertErrorMatch(t, "server error: 404", err)
+}
+
+func TestGitHubListTagsWithBadAuthentication(t *testing.T) {
+	as := makeGitHubTagsServer(t, testToken, nil)
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, "anotherToken")
+
+	_, err := g.ListTags(context.TODO(), "testing/repo")
+	utils.AssertErrorMatch(t, "server error: 401", err)
+}
+
+func TestGitHubListBranches(t *testing.T) {
+	var as *httptest.Server
+	as = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/testing/repo/branches" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "token "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		response := `[{"name":"release/1.1","commit":{"sha":"24317a55785cd98d6c9bf50a5204bc6be17e7316"}}]`
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+as.URL+`/repos/testing/repo/branches?per_page=100&page=2>; rel="next", <`+as.URL+`/repos/testing/repo/branches?per_page=100&page=2>; rel="last"`)
+			response = `[{"name":"main","commit":{"sha":"7638417db6d59f3c431d3e1f261cc637155684cd"}}]`
+		}
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	branches, err := g.ListBranches(context.TODO(), "testing/repo")
+	utils.AssertNoError(t, err)
+
+	want := Branches{Items: []Branch{
+		{Name: "main", SHA: "7638417db6d59f3c431d3e1f261cc637155684cd"},
+		{Name: "release/1.1", SHA: "24317a55785cd98d6c9bf50a5204bc6be17e7316"},
+	}}
+	if diff := cmp.Diff(want, branches); diff != "" {
+		t.Errorf("ListBranches() failed:\n%s", diff)
+	}
+}
+
+func TestGitHubListBranchesWithTooManyPages(t *testing.T) {
+	var as *httptest.Server
+	as = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Link", fmt.Sprintf(`<%s/repos/testing/repo/branches?per_page=100&page=%d>; rel="next"`, as.URL, page+1))
+		fmt.Fprintf(w, `[{"name":"branch-%d","commit":{"sha":"24317a55785cd98d6c9bf50a5204bc6be17e7316"}}]`, page)
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	branches, err := g.ListBranches(context.TODO(), "testing/repo")
+	utils.AssertNoError(t, err)
+
+	if !branches.Truncated {
+		t.Error("expected the branches to be truncated")
+	}
+	if l := len(branches.Items); l != maxBranchPages {
+		t.Errorf("got %d branches, want %d", l, maxBranchPages)
+	}
+}
+
+func TestGitHubListBranchesWithNotFoundResponse(t *testing.T) {
+	as := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	_, err := g.ListBranches(context.TODO(), "testing/repo")
+	utils.AssertErrorMatch(t, "server error: 404", err)
+}
+
+func TestGitHubListPullRequests(t *testing.T) {
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/testing/repo/pulls" || r.URL.Query().Get("state") != "open" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "token "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		response := `[{"number":12,"title":"Add a feature","html_url":"https://github.com/testing/repo/pull/12","state":"open","head":{"ref":"feature","sha":"7638417db6d59f3c431d3e1f261cc637155684cd"},"base":{"ref":"main"},"labels":[{"name":"ready"},{"name":"bug"}]}]`
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	pullRequests, err := g.ListPullRequests(context.TODO(), "testing/repo")
+	utils.AssertNoError(t, err)
+
+	want := []PullRequest{
+		{
+			Number: 12, Title: "Add a feature", URL: "https://github.com/testing/repo/pull/12", State: PullRequestOpen,
+			HeadRef: "feature", HeadSHA: "7638417db6d59f3c431d3e1f261cc637155684cd", BaseRef: "main", Labels: []string{"ready", "bug"},
+		},
+	}
+	if diff := cmp.Diff(want, pullRequests); diff != "" {
+		t.Errorf("ListPullRequests() failed:\n%s", diff)
+	}
+}
+
+func TestGitHubGetPullRequest(t *testing.T) {
+	getTests := []struct {
+		name      string
+		response  string
+		wantState string
+	}{
+		{"merged", `{"number":12,"state":"closed","merged":true,"head":{"ref":"feature","sha":"7638417db6d59f3c431d3e1f261cc637155684cd"},"base":{"ref":"main"}}`, PullRequestMerged},
+		{"closed", `{"number":12,"state":"closed","merged":false,"head":{"ref":"feature","sha":"7638417db6d59f3c431d3e1f261cc637155684cd"},"base":{"ref":"main"}}`, PullRequestClosed},
+	}
+
+	for _, tt := range getTests {
+		t.Run(tt.name, func(t *testing.T) {
+			as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/repos/testing/repo/pulls/12" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				if _, err := w.Write([]byte(tt.response)); err != nil {
+					t.Errorf("failed to write response: %s", err)
+				}
+			}))
+			t.Cleanup(as.Close)
+			g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+			pr, err := g.GetPullRequest(context.TODO(), "testing/repo", 12)
+			utils.AssertNoError(t, err)
+
+			want := PullRequest{Number: 12, State: tt.wantState, HeadRef: "feature", HeadSHA: "7638417db6d59f3c431d3e1f261cc637155684cd", BaseRef: "main"}
+			if diff := cmp.Diff(want, pr); diff != "" {
+				t.Errorf("GetPullRequest() failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGitHubGetPullRequestWithNotFoundResponse(t *testing.T) {
+	as := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	_, err := g.GetPullRequest(context.TODO(), "testing/repo", 12)
+	utils.AssertErrorMatch(t, "server error: 404", err)
+}
+
+func TestGitHubListReleases(t *testing.T) {
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/testing/repo/releases" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "token "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") == "etag-1" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", "etag-1")
+		response := `[{"name":"Release 1.10","tag_name":"v1.10.0","html_url":"https://github.com/testing/repo/releases/tag/v1.10.0","body":"Fixes","draft":false,"prerelease":false,"published_at":"2024-02-10T00:00:00Z","assets":[{"name":"demo.tar.gz","browser_download_url":"https://github.com/testing/repo/releases/download/v1.10.0/demo.tar.gz","content_type":"application/gzip","size":1024}]},{"name":"Next","tag_name":"v2.0.0","draft":true,"published_at":null,"assets":[]}]`
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	releases, err := g.ListReleases(context.TODO(), "testing/repo", "")
+	utils.AssertNoError(t, err)
+
+	want := Releases{
+		Items: []Release{
+			{
+				Name: "Release 1.10", Tag: "v1.10.0", URL: "https://github.com/testing/repo/releases/tag/v1.10.0", Body: "Fixes",
+				Published: time.Date(2024, time.February, 10, 0, 0, 0, 0, time.UTC),
+				Assets:    []ReleaseAsset{{Name: "demo.tar.gz", URL: "https://github.com/testing/repo/releases/download/v1.10.0/demo.tar.gz", ContentType: "application/gzip", Size: 1024}},
+			},
+			{Name: "Next", Tag: "v2.0.0", Draft: true},
+		},
+		ETag: "etag-1",
+	}
+	if diff := cmp.Diff(want, releases); diff != "" {
+		t.Errorf("ListReleases() failed:\n%s", diff)
+	}
+
+	releases, err = g.ListReleases(context.TODO(), "testing/repo", "etag-1")
+	utils.AssertNoError(t, err)
+
+	if diff := cmp.Diff(Releases{ETag: "etag-1", NotModified: true}, releases); diff != "" {
+		t.Errorf("ListReleases() with a known ETag failed:\n%s", diff)
+	}
+}
+
+// makeGitHubTagsServer responds to each tags query with the next page.
+func makeGitHubTagsServer(t *testing.T, authToken string, pages []string) *httptest.Server {
+	page := 0
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "bearer "+authToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var query githubGraphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		wantVariables := map[string]string{"owner": "testing", "name": "repo"}
+		if page > 0 {
+			wantVariables["after"] = "cursor" + strconv.Itoa(page)
+		}
+		if diff := cmp.Diff(wantVariables, query.Variables); diff != "" {
+			t.Errorf("query variables failed:\n%s", diff)
+		}
+		if page >= len(pages) {
+			t.Errorf("unexpected query for page %d", page)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(pages[page])); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+		page++
+	}))
+}
+
 func mustReadFile(t *testing.T, filename string) []byte {
 	t.Helper()
 	d, err := os.ReadFile(filename)
@@ -0,0 +1,241 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+const (
+	// GitHub rejects JWTs that expire more than 10 minutes in the future.
+	githubAppJWTExpiry = 9 * time.Minute
+	// The JWT is backdated to allow for clock drift.
+	githubAppJWTClockSkew = 60 * time.Second
+	// Installation tokens are refreshed this long before they expire.
+	githubAppTokenRefreshMargin = 5 * time.Minute
+)
+
+// GitHubAppTokenSource is a TokenSource that authenticates as a GitHub App
+// installation.
+//
+// A JWT signed with the App's private key is exchanged for an installation
+// access token, which is cached until shortly before it expires.
+type GitHubAppTokenSource struct {
+	client         *http.Client
+	endpoint       string
+	appID          string
+	installationID string
+	privateKey     *rsa.PrivateKey
+	now            func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+// NewGitHubAppTokenSource creates and returns a new GitHubAppTokenSource.
+//
+// The endpoint is the GitHub API endpoint e.g. https://api.github.com and the
+// privateKey is the PEM encoded private key for the App.
+func NewGitHubAppTokenSource(c *http.Client, endpoint, appID, installationID string, privateKey []byte) (*GitHubAppTokenSource, error) {
+	key, err := parseRSAPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if appID == "" || installationID == "" {
+		return nil, errors.New("the GitHub App ID and installation ID are required")
+	}
+	return &GitHubAppTokenSource{
+		client:         c,
+		endpoint:       endpoint,
+		appID:          appID,
+		installationID: installationID,
+		privateKey:     key,
+		now:            time.Now,
+	}, nil
+}
+
+// Token implements the TokenSource interface.
+func (s *GitHubAppTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && s.now().Before(s.expiresAt.Add(-githubAppTokenRefreshMargin)) {
+		return s.token, nil
+	}
+
+	token, expiresAt, err := s.installationToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiresAt = token, expiresAt
+	return token, nil
+}
+
+type githubInstallationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (s *GitHubAppTokenSource) installationToken(ctx context.Context) (string, time.Time, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", s.endpoint, "appID", s.appID, "installationID", s.installationID)
+	jwt, err := s.signedJWT()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	requestURL, err := url.JoinPath(s.endpoint, "app/installations", s.installationID, "access_tokens")
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	logger.Info("requesting GitHub App installation token", "url", requestURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, nil)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create request for GitHub: %w", err)
+	}
+	req.Header.Add("Accept", "application/vnd.github+json")
+	req.Header.Add("Authorization", "Bearer "+jwt)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		logger.Error(err, "requesting GitHub App installation token")
+		return "", time.Time{}, requestError(err, fmt.Errorf("failed to get installation token: %v", err))
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from GitHub")
+		}
+	}()
+	logger.Info("requested GitHub App installation token", "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return "", time.Time{}, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, requestError(err, fmt.Errorf("reading body from GitHub: %w", err))
+	}
+	var token githubInstallationToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", time.Time{}, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	if token.Token == "" {
+		return "", time.Time{}, errors.New("no installation token in the response")
+	}
+	return token.Token, token.ExpiresAt, nil
+}
+
+// signedJWT creates an RS256 signed JWT to authenticate as the App.
+//
+// https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+func (s *GitHubAppTokenSource) signedJWT() (string, error) {
+	now := s.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-githubAppJWTClockSkew).Unix(),
+		"exp": now.Add(githubAppJWTExpiry).Unix(),
+		"iss": s.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the JWT: %w", err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseRSAPrivateKey parses the PEM encoded key, GitHub provides PKCS#1 keys
+// but PKCS#8 is also accepted.
+func parseRSAPrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("failed to parse the GitHub App private key: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the GitHub App private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("failed to parse the GitHub App private key: not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// GitHubAppTokenSources caches a GitHubAppTokenSource for each App
+// installation, so that the installation tokens are reused between polls.
+type GitHubAppTokenSources struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	sources map[string]*GitHubAppTokenSource
+}
+
+// NewGitHubAppTokenSources creates and returns a new GitHubAppTokenSources.
+func NewGitHubAppTokenSources(c *http.Client) *GitHubAppTokenSources {
+	return &GitHubAppTokenSources{client: c, sources: map[string]*GitHubAppTokenSource{}}
+}
+
+// TokenSource returns the cached TokenSource for the App installation,
+// creating it if needed.
+//
+// The private key is part of the cache key, so rotating the key creates a
+// new TokenSource.
+func (s *GitHubAppTokenSources) TokenSource(endpoint, appID, installationID string, privateKey []byte) (*GitHubAppTokenSource, error) {
+	h := sha256.New()
+	for _, v := range [][]byte{[]byte(endpoint), []byte(appID), []byte(installationID), privateKey} {
+		h.Write(v)
+		h.Write([]byte{0})
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ts, ok := s.sources[key]; ok {
+		return ts, nil
+	}
+	ts, err := NewGitHubAppTokenSource(s.client, endpoint, appID, installationID, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	s.sources[key] = ts
+	return ts, nil
+}
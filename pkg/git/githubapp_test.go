@@ -0,0 +1,240 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+)
+
+const (
+	testAppID          = "12345"
+	testInstallationID = "67890"
+)
+
+var _ TokenSource = (*GitHubAppTokenSource)(nil)
+var _ TokenSource = StaticToken("")
+
+func TestGitHubAppTokenSource(t *testing.T) {
+	key, pemKey := makeRSAKey(t)
+	now := time.Date(2024, time.March, 12, 9, 0, 0, 0, time.UTC)
+	as, requests := makeGitHubAppServer(t, &key.PublicKey, now.Add(time.Hour))
+	t.Cleanup(as.Close)
+	ts, err := NewGitHubAppTokenSource(as.Client(), as.URL, testAppID, testInstallationID, pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.now = func() time.Time { return now }
+
+	token, err := ts.Token(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "ghs_token-1" {
+		t.Errorf("Token() got %q, want %q", token, "ghs_token-1")
+	}
+
+	// The token is cached until it's near expiry.
+	now = now.Add(50 * time.Minute)
+	token, err = ts.Token(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "ghs_token-1" {
+		t.Errorf("Token() got %q, want the cached token", token)
+	}
+
+	now = now.Add(6 * time.Minute)
+	token, err = ts.Token(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "ghs_token-2" {
+		t.Errorf("Token() got %q, want a refreshed token", token)
+	}
+	if *requests != 2 {
+		t.Errorf("got %d token requests, want 2", *requests)
+	}
+}
+
+func TestGitHubAppTokenSourceWithUnknownKey(t *testing.T) {
+	key, _ := makeRSAKey(t)
+	_, otherKey := makeRSAKey(t)
+	as, _ := makeGitHubAppServer(t, &key.PublicKey, time.Now().Add(time.Hour))
+	t.Cleanup(as.Close)
+	ts, err := NewGitHubAppTokenSource(as.Client(), as.URL, testAppID, testInstallationID, otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ts.Token(context.TODO())
+	utils.AssertErrorMatch(t, "server error: 401", err)
+}
+
+func TestNewGitHubAppTokenSource_errors(t *testing.T) {
+	_, pemKey := makeRSAKey(t)
+	newTests := []struct {
+		appID          string
+		installationID string
+		privateKey     []byte
+		wantErr        string
+	}{
+		{testAppID, testInstallationID, []byte("not a key"), "no PEM data found"},
+		{"", testInstallationID, pemKey, "the GitHub App ID and installation ID are required"},
+		{testAppID, "", pemKey, "the GitHub App ID and installation ID are required"},
+	}
+
+	for _, tt := range newTests {
+		_, err := NewGitHubAppTokenSource(http.DefaultClient, "https://api.github.com", tt.appID, tt.installationID, tt.privateKey)
+		utils.AssertErrorMatch(t, tt.wantErr, err)
+	}
+}
+
+func TestGitHubWithAppTokenSource(t *testing.T) {
+	key, pemKey := makeRSAKey(t)
+	tokenServer, _ := makeGitHubAppServer(t, &key.PublicKey, time.Now().Add(time.Hour))
+	t.Cleanup(tokenServer.Close)
+	ts, err := NewGitHubAppTokenSource(tokenServer.Client(), tokenServer.URL, testAppID, testInstallationID, pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	as := utils.MakeGitHubAPIServer(t, "ghs_token-1", "/repos/testing/repo/commits/master", testEtag, mustReadFile(t, "testdata/github_commit.json"))
+	t.Cleanup(as.Close)
+	g := NewGitHubPollerWithTokenSource(as.Client(), as.URL, ts)
+
+	polled, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.ETag != testEtag {
+		t.Errorf("Poll() ETag got %s, want %s", polled.ETag, testEtag)
+	}
+}
+
+func TestGitHubAppTokenSources(t *testing.T) {
+	_, pemKey := makeRSAKey(t)
+	_, rotatedKey := makeRSAKey(t)
+	sources := NewGitHubAppTokenSources(http.DefaultClient)
+
+	ts1, err := sources.TokenSource("https://api.github.com", testAppID, testInstallationID, pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2, err := sources.TokenSource("https://api.github.com", testAppID, testInstallationID, pemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts1 != ts2 {
+		t.Error("TokenSource() did not return the cached token source")
+	}
+
+	ts3, err := sources.TokenSource("https://api.github.com", testAppID, testInstallationID, rotatedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts1 == ts3 {
+		t.Error("TokenSource() returned the cached token source for a different key")
+	}
+}
+
+// makeGitHubAppServer is used during testing to create an HTTP server that
+// issues installation tokens when the request has a JWT signed by the key.
+//
+// The number of tokens issued is recorded.
+func makeGitHubAppServer(t *testing.T, key *rsa.PublicKey, expiresAt time.Time) (*httptest.Server, *int) {
+	var issued int
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/"+testInstallationID+"/access_tokens" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || verifyJWT(key, jwt) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		issued++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		token := githubInstallationToken{Token: fmt.Sprintf("ghs_token-%d", issued), ExpiresAt: expiresAt}
+		if err := json.NewEncoder(w).Encode(token); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	})), &issued
+}
+
+func verifyJWT(key *rsa.PublicKey, jwt string) error {
+	elements := strings.Split(jwt, ".")
+	if len(elements) != 3 {
+		return fmt.Errorf("invalid JWT %q", jwt)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(elements[2])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256([]byte(elements[0] + "." + elements[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return err
+	}
+	b, err := base64.RawURLEncoding.DecodeString(elements[1])
+	if err != nil {
+		return err
+	}
+	var claims struct {
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+		Issuer    string `json:"iss"`
+	}
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return err
+	}
+	if claims.Issuer != testAppID {
+		return fmt.Errorf("got issuer %q", claims.Issuer)
+	}
+	if d := time.Duration(claims.ExpiresAt-claims.IssuedAt) * time.Second; d > 10*time.Minute {
+		return fmt.Errorf("JWT is valid for %s", d)
+	}
+	return nil
+}
+
+// makeRSAKey creates a new key, and returns the key and the PEM encoded
+// private key.
+func makeRSAKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
@@ -0,0 +1,363 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+const (
+	// maxGitHubBatchSize is the maximum number of repositories in a query,
+	// when a batch reaches this size it is sent without waiting.
+	maxGitHubBatchSize = 100
+	// githubBatchTimeout limits how long the query for a batch can take.
+	githubBatchTimeout = 30 * time.Second
+)
+
+// GitHubBatcher aggregates polls for GitHub repositories into a single
+// GraphQL query.
+//
+// Polls for the same endpoint and token that are made within the window are
+// sent together, and the results are returned to each poller.
+//
+// The query is sent with the client of the first poll in the batch, the polls
+// in a batch share the token, and so they share the rate limit.
+type GitHubBatcher struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	batches map[githubBatchKey]*githubBatch
+}
+
+type githubBatchKey struct {
+	endpoint string
+	token    string
+}
+
+type githubBatch struct {
+	logger   logr.Logger
+	requests []*githubBatchRequest
+}
+
+type githubBatchRequest struct {
+	client *http.Client
+	owner  string
+	name   string
+	ref    string
+	result chan githubBatchResult
+}
+
+type githubBatchResult struct {
+	sha string
+	err error
+}
+
+// NewGitHubBatcher creates and returns a new GitHubBatcher.
+//
+// The window is how long to wait for other polls before sending the query.
+func NewGitHubBatcher(window time.Duration) *GitHubBatcher {
+	return &GitHubBatcher{window: window, batches: map[githubBatchKey]*githubBatch{}}
+}
+
+// Poller returns a CommitPoller that polls through the batcher.
+func (b *GitHubBatcher) Poller(c *http.Client, endpoint string, tokens TokenSource) *GitHubBatchPoller {
+	return &GitHubBatchPoller{batcher: b, client: c, endpoint: endpoint, tokens: tokens}
+}
+
+// commitSHARE matches refs that may be a commit SHA, the GraphQL ref field
+// only resolves branches and tags.
+var commitSHARE = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// GitHubBatchPoller is a CommitPoller that polls GitHub using a GraphQL query
+// that is shared with other repositories.
+//
+// The GraphQL API doesn't support ETags, the polled status has no ETag, and
+// the commit has only the SHA.
+//
+// Refs that may be a commit SHA are polled with the REST API, and the commit
+// is the full commit from the REST API.
+type GitHubBatchPoller struct {
+	batcher  *GitHubBatcher
+	client   *http.Client
+	endpoint string
+	tokens   TokenSource
+}
+
+func (g GitHubBatchPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("invalid GitHub repository %q", repo)
+	}
+	if commitSHARE.MatchString(pr.Ref) {
+		logger.Info("polling GitHub repo without batching, the ref may be a commit SHA", "ref", pr.Ref)
+		return NewGitHubPollerWithTokenSource(g.client, g.endpoint, g.tokens).Poll(ctx, repo, pr)
+	}
+	authToken, err := g.tokens.Token(ctx)
+	if err != nil {
+		logger.Error(err, "getting the GitHub token")
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to get the token: %w", err)
+	}
+
+	logger.Info("polling GitHub repo in batch", "ref", pr.Ref)
+	req := &githubBatchRequest{client: g.client, owner: owner, name: name, ref: pr.Ref, result: make(chan githubBatchResult, 1)}
+	g.batcher.add(ctx, githubBatchKey{endpoint: g.endpoint, token: authToken}, req)
+
+	var result githubBatchResult
+	select {
+	case result = <-req.result:
+	case <-ctx.Done():
+		return pollingv1alpha1.PollStatus{}, nil, ctx.Err()
+	}
+	if result.err != nil {
+		logger.Error(result.err, "polling GitHub repo in batch")
+		return pollingv1alpha1.PollStatus{}, nil, result.err
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", result.sha)
+	// The ETag from a previous REST poll is kept until the commit changes.
+	if result.sha == pr.SHA {
+		return pr, nil, nil
+	}
+
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: result.sha}, Commit{"sha": result.sha}, nil
+}
+
+// add queues the request, the first request for a key starts the window, and
+// a full batch is sent immediately.
+func (b *GitHubBatcher) add(ctx context.Context, key githubBatchKey, req *githubBatchRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	batch, ok := b.batches[key]
+	if !ok {
+		batch = &githubBatch{logger: logr.FromContextOrDiscard(ctx).WithValues("endpoint", key.endpoint)}
+		b.batches[key] = batch
+		time.AfterFunc(b.window, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.flush(key, batch)
+		})
+	}
+	batch.requests = append(batch.requests, req)
+	if len(batch.requests) >= maxGitHubBatchSize {
+		b.flush(key, batch)
+	}
+}
+
+// flush must be called with the lock held, the batch is only sent if it is
+// still pending for the key.
+func (b *GitHubBatcher) flush(key githubBatchKey, batch *githubBatch) {
+	if b.batches[key] != batch {
+		return
+	}
+	delete(b.batches, key)
+	go b.send(key, batch)
+}
+
+func (b *GitHubBatcher) send(key githubBatchKey, batch *githubBatch) {
+	ctx, cancel := context.WithTimeout(context.Background(), githubBatchTimeout)
+	defer cancel()
+	results, err := b.query(ctx, batch.logger, key, batch.requests)
+	for i, req := range batch.requests {
+		if err != nil {
+			req.result <- githubBatchResult{err: err}
+			continue
+		}
+		req.result <- results[i]
+	}
+}
+
+type githubGraphQLRequest struct {
+	Query     string            `json:"query"`
+	Variables map[string]string `json:"variables"`
+}
+
+type githubGraphQLResponse struct {
+	Data   map[string]*githubGraphQLRepository `json:"data"`
+	Errors []githubGraphQLError                `json:"errors"`
+}
+
+type githubGraphQLRepository struct {
+	Ref *struct {
+		Target struct {
+			OID string `json:"oid"`
+			// Annotated tags point to the tagged commit.
+			Target *struct {
+				OID string `json:"oid"`
+			} `json:"target"`
+		} `json:"target"`
+	} `json:"ref"`
+}
+
+type githubGraphQLError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Path    []any  `json:"path"`
+}
+
+func (b *GitHubBatcher) query(ctx context.Context, logger logr.Logger, key githubBatchKey, requests []*githubBatchRequest) ([]githubBatchResult, error) {
+	requestURL, err := githubGraphQLURL(key.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	body, err := json.Marshal(makeGitHubBatchQuery(requests))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the query: %w", err)
+	}
+	logger.Info("polling GitHub repos", "url", requestURL, "repos", len(requests))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for GitHub: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if key.token != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("bearer %s", key.token))
+	}
+	client := requests[0].client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		logger.Error(err, "polling GitHub repos")
+		return nil, requestError(err, fmt.Errorf("failed to get current commit: %v", err))
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from GitHub")
+		}
+	}()
+	logger.Info("polled GitHub repos", "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, requestError(err, fmt.Errorf("reading body from GitHub: %w", err))
+	}
+	var response githubGraphQLResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		logger.Error(err, "unmarshalling GitHub response")
+		return nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+
+	// Errors are associated with a repository by the alias in the path, any
+	// other errors fail the whole query.
+	aliasErrors := map[string]error{}
+	for _, e := range response.Errors {
+		alias, ok := "", len(e.Path) > 0
+		if ok {
+			alias, ok = e.Path[0].(string)
+		}
+		if !ok {
+			return nil, fmt.Errorf("GitHub query failed: %s", e.Message)
+		}
+		if _, ok := aliasErrors[alias]; !ok {
+			aliasErrors[alias] = githubGraphQLErr(e)
+		}
+	}
+
+	results := make([]githubBatchResult, len(requests))
+	for i, req := range requests {
+		alias := githubBatchAlias(i)
+		if err := aliasErrors[alias]; err != nil {
+			results[i] = githubBatchResult{err: err}
+			continue
+		}
+		repo := response.Data[alias]
+		if repo == nil || repo.Ref == nil {
+			results[i] = githubBatchResult{err: withKind(ErrNotFound, fmt.Errorf("no commits found for ref %q", req.ref))}
+			continue
+		}
+		sha := repo.Ref.Target.OID
+		if repo.Ref.Target.Target != nil {
+			sha = repo.Ref.Target.Target.OID
+		}
+		results[i] = githubBatchResult{sha: sha}
+	}
+
+	return results, nil
+}
+
+func githubGraphQLErr(e githubGraphQLError) error {
+	switch e.Type {
+	case "NOT_FOUND":
+		return &StatusError{StatusCode: http.StatusNotFound}
+	case "FORBIDDEN":
+		return withKind(ErrForbidden, fmt.Errorf("GitHub query failed: %s", e.Message))
+	}
+	return fmt.Errorf("GitHub query failed: %s", e.Message)
+}
+
+// makeGitHubBatchQuery creates a query with an aliased repository field for
+// each request, the values are passed as variables to avoid quoting.
+func makeGitHubBatchQuery(requests []*githubBatchRequest) githubGraphQLRequest {
+	var params, fields strings.Builder
+	variables := map[string]string{}
+	for i, req := range requests {
+		alias := githubBatchAlias(i)
+		if i > 0 {
+			params.WriteString(", ")
+		}
+		fmt.Fprintf(&params, "$%[1]s_owner: String!, $%[1]s_name: String!, $%[1]s_ref: String!", alias)
+		fmt.Fprintf(&fields, " %[1]s: repository(owner: $%[1]s_owner, name: $%[1]s_name) { ref(qualifiedName: $%[1]s_ref) { target { oid ... on Tag { target { oid } } } } }", alias)
+		variables[alias+"_owner"] = req.owner
+		variables[alias+"_name"] = req.name
+		variables[alias+"_ref"] = req.ref
+	}
+
+	return githubGraphQLRequest{
+		Query:     fmt.Sprintf("query(%s) {%s }", params.String(), fields.String()),
+		Variables: variables,
+	}
+}
+
+func githubBatchAlias(i int) string {
+	return "r" + strconv.Itoa(i)
+}
+
+// githubGraphQLURL returns the GraphQL endpoint for the REST API endpoint.
+//
+// GitHub Enterprise Server serves the REST API from /api/v3 and GraphQL from
+// /api/graphql, other hosts serve GraphQL from /graphql.
+func githubGraphQLURL(endpoint string) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if base, ok := strings.CutSuffix(strings.TrimSuffix(parsed.Path, "/"), "/api/v3"); ok {
+		parsed.Path = path.Join(base, "/api/graphql")
+		return parsed.String(), nil
+	}
+	parsed.Path = path.Join(parsed.Path, "graphql")
+
+	return parsed.String(), nil
+}
@@ -0,0 +1,340 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+	"github.com/google/go-cmp/cmp"
+)
+
+const testBatchWindow = 50 * time.Millisecond
+
+var _ CommitPoller = (*GitHubBatchPoller)(nil)
+
+func TestGitHubBatchPoller(t *testing.T) {
+	as, queries := makeGitHubGraphQLServer(t, testToken, map[string]string{
+		"testing/repo:master":  "7638417db6d59f3c431d3e1f261cc637155684cd",
+		"testing/other:main":   "24317a55785cd98d6c9bf50a5204bc6be17e7316",
+		"testing/other:v1.0.0": "ba5c2bdc6e0fa5b2ab3d1ee8fba0e5b0e5a93a3f",
+	})
+	t.Cleanup(as.Close)
+	b := NewGitHubBatcher(testBatchWindow)
+
+	pollTests := []struct {
+		repo    string
+		ref     string
+		wantSHA string
+	}{
+		{"testing/repo", "master", "7638417db6d59f3c431d3e1f261cc637155684cd"},
+		{"testing/other", "main", "24317a55785cd98d6c9bf50a5204bc6be17e7316"},
+		{"testing/other", "v1.0.0", "ba5c2bdc6e0fa5b2ab3d1ee8fba0e5b0e5a93a3f"},
+	}
+
+	var wg sync.WaitGroup
+	for _, tt := range pollTests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g := b.Poller(as.Client(), as.URL, StaticToken(testToken))
+			polled, commit, err := g.Poll(context.TODO(), tt.repo, pollingv1alpha1.PollStatus{Ref: tt.ref})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if diff := cmp.Diff(pollingv1alpha1.PollStatus{Ref: tt.ref, SHA: tt.wantSHA}, polled); diff != "" {
+				t.Errorf("Poll() %s failed:\n%s", tt.repo, diff)
+			}
+			if diff := cmp.Diff(Commit{"sha": tt.wantSHA}, commit); diff != "" {
+				t.Errorf("Poll() %s commit failed:\n%s", tt.repo, diff)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(*queries); n != 1 {
+		t.Errorf("got %d queries, want 1", n)
+	}
+}
+
+func TestGitHubBatchPollerWithUnchangedCommit(t *testing.T) {
+	as, _ := makeGitHubGraphQLServer(t, testToken, map[string]string{
+		"testing/repo:master": "7638417db6d59f3c431d3e1f261cc637155684cd",
+	})
+	t.Cleanup(as.Close)
+	g := NewGitHubBatcher(testBatchWindow).Poller(as.Client(), as.URL, StaticToken(testToken))
+	status := pollingv1alpha1.PollStatus{Ref: "master", SHA: "7638417db6d59f3c431d3e1f261cc637155684cd", ETag: testEtag}
+
+	polled, commit, err := g.Poll(context.TODO(), "testing/repo", status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(status, polled); diff != "" {
+		t.Errorf("Poll() failed:\n%s", diff)
+	}
+	if commit != nil {
+		t.Errorf("for unchanged commit, got %#v, want nil", commit)
+	}
+}
+
+func TestGitHubBatchPollerWithNotFoundRepository(t *testing.T) {
+	as, _ := makeGitHubGraphQLServer(t, testToken, map[string]string{
+		"testing/repo:master": "7638417db6d59f3c431d3e1f261cc637155684cd",
+	})
+	t.Cleanup(as.Close)
+	b := NewGitHubBatcher(testBatchWindow)
+
+	var wg sync.WaitGroup
+	var found, notFound error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _, found = b.Poller(as.Client(), as.URL, StaticToken(testToken)).Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	}()
+	go func() {
+		defer wg.Done()
+		_, _, notFound = b.Poller(as.Client(), as.URL, StaticToken(testToken)).Poll(context.TODO(), "testing/testing", pollingv1alpha1.PollStatus{Ref: "master"})
+	}()
+	wg.Wait()
+
+	utils.AssertNoError(t, found)
+	utils.AssertErrorMatch(t, "server error: 404", notFound)
+}
+
+func TestGitHubBatchPollerWithUnknownRef(t *testing.T) {
+	as, _ := makeGitHubGraphQLServer(t, testToken, map[string]string{
+		"testing/repo:master": "7638417db6d59f3c431d3e1f261cc637155684cd",
+	})
+	t.Cleanup(as.Close)
+	g := NewGitHubBatcher(testBatchWindow).Poller(as.Client(), as.URL, StaticToken(testToken))
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "unknown"})
+	utils.AssertErrorMatch(t, `no commits found for ref "unknown"`, err)
+}
+
+func TestGitHubBatchPollerUsesThePollerClient(t *testing.T) {
+	as, _ := makeGitHubGraphQLServer(t, testToken, map[string]string{
+		"testing/repo:master": "7638417db6d59f3c431d3e1f261cc637155684cd",
+	})
+	t.Cleanup(as.Close)
+	client := as.Client()
+	recording := &recordingTransport{base: client.Transport}
+	client.Transport = recording
+	g := NewGitHubBatcher(testBatchWindow).Poller(client, as.URL, StaticToken(testToken))
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	utils.AssertNoError(t, err)
+
+	if recording.requests != 1 {
+		t.Errorf("got %d requests with the poller client, want 1", recording.requests)
+	}
+}
+
+func TestGitHubBatchPollerWithCommitSHA(t *testing.T) {
+	sha := "7638417db6d59f3c431d3e1f261cc637155684cd"
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/testing/repo/commits/"+sha {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", testEtag)
+		if _, err := w.Write([]byte(`{"sha":"` + sha + `","commit":{"message":"Initial commit"}}`)); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitHubBatcher(testBatchWindow).Poller(as.Client(), as.URL, StaticToken(testToken))
+
+	polled, commit, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: sha})
+	utils.AssertNoError(t, err)
+
+	if diff := cmp.Diff(pollingv1alpha1.PollStatus{Ref: sha, SHA: sha, ETag: testEtag}, polled); diff != "" {
+		t.Errorf("Poll() failed:\n%s", diff)
+	}
+	want := Commit{"sha": sha, "commit": map[string]any{"message": "Initial commit"}}
+	if diff := cmp.Diff(want, commit); diff != "" {
+		t.Errorf("Poll() commit failed:\n%s", diff)
+	}
+}
+
+func TestGitHubBatchPollerWithBadAuthentication(t *testing.T) {
+	as, _ := makeGitHubGraphQLServer(t, testToken, nil)
+	t.Cleanup(as.Close)
+	g := NewGitHubBatcher(testBatchWindow).Poller(as.Client(), as.URL, StaticToken("anotherToken"))
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	utils.AssertErrorMatch(t, "server error: 401", err)
+}
+
+func TestGitHubBatchPollerWithDifferentTokens(t *testing.T) {
+	as, queries := makeGitHubGraphQLServer(t, "", map[string]string{
+		"testing/repo:master": "7638417db6d59f3c431d3e1f261cc637155684cd",
+	})
+	t.Cleanup(as.Close)
+	b := NewGitHubBatcher(testBatchWindow)
+
+	var wg sync.WaitGroup
+	for _, token := range []string{"token1", "token2"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := b.Poller(as.Client(), as.URL, StaticToken(token)).Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+			utils.AssertNoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	if n := len(*queries); n != 2 {
+		t.Errorf("got %d queries, want 2", n)
+	}
+}
+
+func TestGitHubBatchPollerWithFullBatch(t *testing.T) {
+	as, queries := makeGitHubGraphQLServer(t, testToken, map[string]string{
+		"testing/repo:master": "7638417db6d59f3c431d3e1f261cc637155684cd",
+	})
+	t.Cleanup(as.Close)
+	// The window is longer than the test timeout, so only a full batch is
+	// sent.
+	b := NewGitHubBatcher(time.Hour)
+
+	var wg sync.WaitGroup
+	for range maxGitHubBatchSize {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := b.Poller(as.Client(), as.URL, StaticToken(testToken)).Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+			utils.AssertNoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	if n := len(*queries); n != 1 {
+		t.Errorf("got %d queries, want 1", n)
+	}
+}
+
+func TestGitHubBatchPollerWithCancelledContext(t *testing.T) {
+	b := NewGitHubBatcher(time.Hour)
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	_, _, err := b.Poller(http.DefaultClient, "https://api.github.com", StaticToken(testToken)).Poll(ctx, "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	utils.AssertErrorMatch(t, "context canceled", err)
+}
+
+func TestGitHubGraphQLURL(t *testing.T) {
+	urlTests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://api.github.com", "https://api.github.com/graphql"},
+		{"https://api.octocorp.ghe.com", "https://api.octocorp.ghe.com/graphql"},
+		{"https://github.example.com/api/v3", "https://github.example.com/api/graphql"},
+		{"https://github.example.com/api/v3/", "https://github.example.com/api/graphql"},
+	}
+
+	for _, tt := range urlTests {
+		got, err := githubGraphQLURL(tt.endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("githubGraphQLURL(%q) got %q, want %q", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+// makeGitHubGraphQLServer is used during testing to create an HTTP server that
+// responds to batched queries with the SHAs for "owner/name:ref".
+//
+// If the authToken is empty, any token is accepted, the queries are recorded.
+func makeGitHubGraphQLServer(t *testing.T, authToken string, shas map[string]string) (*httptest.Server, *[]githubGraphQLRequest) {
+	var mu sync.Mutex
+	var queries []githubGraphQLRequest
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/graphql" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if authToken != "" && r.Header.Get("Authorization") != "bearer "+authToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var query githubGraphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		queries = append(queries, query)
+		mu.Unlock()
+
+		response := map[string]any{}
+		data := map[string]any{}
+		var errors []map[string]any
+		for key, owner := range query.Variables {
+			alias, ok := strings.CutSuffix(key, "_owner")
+			if !ok {
+				continue
+			}
+			if !strings.Contains(query.Query, alias+": repository(") {
+				t.Errorf("query is missing the alias %q", alias)
+			}
+			repo := owner + "/" + query.Variables[alias+"_name"]
+			sha, ok := shas[repo+":"+query.Variables[alias+"_ref"]]
+			switch {
+			case ok:
+				data[alias] = map[string]any{"ref": map[string]any{"target": map[string]any{"oid": sha}}}
+			case strings.HasPrefix(repo, "testing/testing"):
+				data[alias] = nil
+				errors = append(errors, map[string]any{"type": "NOT_FOUND", "path": []string{alias}, "message": "Could not resolve to a Repository"})
+			default:
+				data[alias] = map[string]any{"ref": nil}
+			}
+		}
+		response["data"] = data
+		if len(errors) > 0 {
+			response["errors"] = errors
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	})), &queries
+}
+
+// recordingTransport counts the requests made with a client.
+type recordingTransport struct {
+	base     http.RoundTripper
+	requests int
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return t.base.RoundTrip(req)
+}
@@ -22,7 +22,9 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
 	"github.com/go-logr/logr"
@@ -62,13 +64,11 @@ func (g GitLabPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.
 	resp, err := g.client.Do(req)
 	if err != nil {
 		logger.Error(err, "polling GitLab repo")
-		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to get current commit: %v", err)
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("failed to get current commit: %v", err))
 	}
-	// TODO: Return an error type that we can identify as a NotFound, likely
-	// this is either a security token issue, or an unknown repo.
 	logger.Info("polled GitLab repo", "status", resp.StatusCode)
-	if resp.StatusCode >= http.StatusBadRequest {
-		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("server error: %d", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
 	}
 	if resp.StatusCode == http.StatusNotModified {
 		return pr, nil, nil
@@ -81,17 +81,326 @@ func (g GitLabPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("reading body from GitLab: %w", err)
+		return pollingv1alpha1.PollStatus{}, nil, requestError(err, fmt.Errorf("reading body from GitLab: %w", err))
 	}
 	var gc []map[string]interface{}
 	err = json.Unmarshal(body, &gc)
 	if err != nil {
 		logger.Error(err, "unmarshalling GitLab response")
-		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to decode response body: %w", err)
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	if len(gc) == 0 {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrNotFound, fmt.Errorf("no commits found for ref %q", pr.Ref))
 	}
 	commit := gc[0]
-	logger.Info("poll complete", "ref", pr.Ref, "sha", commit["id"])
-	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: commit["id"].(string), ETag: resp.Header.Get("ETag")}, commit, nil
+	sha, ok := commit["id"].(string)
+	if !ok {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrMalformedResponse, fmt.Errorf("no commit SHA found for ref %q", pr.Ref))
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha, ETag: resp.Header.Get("ETag")}, commit, nil
+}
+
+type gitlabTag struct {
+	Name string `json:"name"`
+	// CreatedAt is only provided for annotated tags.
+	CreatedAt *time.Time `json:"created_at"`
+	Commit    struct {
+		ID            string    `json:"id"`
+		CommittedDate time.Time `json:"committed_date"`
+	} `json:"commit"`
+}
+
+// ListTags lists the tags in the repository, this follows the pagination up
+// to maxTagPages.
+func (g GitLabPoller) ListTags(ctx context.Context, repo string) ([]Tag, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	var tags []Tag
+	page := "1"
+	for range maxTagPages {
+		requestURL := makeGitLabListURL(g.endpoint, repo, "tags", page)
+		logger.Info("listing GitLab tags", "url", requestURL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request for GitLab: %w", err)
+		}
+		if g.authToken != "" {
+			req.Header.Add("Private-Token", g.authToken)
+		}
+		resp, err := g.client.Do(req)
+		if err != nil {
+			logger.Error(err, "listing GitLab tags")
+			return nil, requestError(err, fmt.Errorf("failed to list tags: %v", err))
+		}
+		body, readErr := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from GitLab")
+		}
+		logger.Info("listed GitLab tags", "status", resp.StatusCode)
+		if err := checkResponse(resp); err != nil {
+			return nil, err
+		}
+		if readErr != nil {
+			return nil, requestError(readErr, fmt.Errorf("reading body from GitLab: %w", readErr))
+		}
+		var gt []gitlabTag
+		if err := json.Unmarshal(body, &gt); err != nil {
+			logger.Error(err, "unmarshalling GitLab response")
+			return nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+		}
+		for _, t := range gt {
+			created := t.Commit.CommittedDate
+			if t.CreatedAt != nil {
+				created = *t.CreatedAt
+			}
+			tags = append(tags, Tag{Name: t.Name, SHA: t.Commit.ID, Created: created})
+		}
+		if page = resp.Header.Get("X-Next-Page"); page == "" {
+			return tags, nil
+		}
+	}
+	logger.Info("listed the maximum number of tag pages", "tags", len(tags))
+
+	return tags, nil
+}
+
+type gitlabBranch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+// ListBranches lists the branches in the repository, this follows the
+// pagination up to maxBranchPages, and the branches are Truncated if there are
+// more pages.
+func (g GitLabPoller) ListBranches(ctx context.Context, repo string) (Branches, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	var branches []Branch
+	page := "1"
+	for range maxBranchPages {
+		requestURL := makeGitLabListURL(g.endpoint, repo, "branches", page)
+		logger.Info("listing GitLab branches", "url", requestURL)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+		if err != nil {
+			return Branches{}, fmt.Errorf("failed to create request for GitLab: %w", err)
+		}
+		if g.authToken != "" {
+			req.Header.Add("Private-Token", g.authToken)
+		}
+		resp, err := g.client.Do(req)
+		if err != nil {
+			logger.Error(err, "listing GitLab branches")
+			return Branches{}, requestError(err, fmt.Errorf("failed to list branches: %v", err))
+		}
+		body, readErr := io.ReadAll(resp.Body)
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from GitLab")
+		}
+		logger.Info("listed GitLab branches", "status", resp.StatusCode)
+		if err := checkResponse(resp); err != nil {
+			return Branches{}, err
+		}
+		if readErr != nil {
+			return Branches{}, requestError(readErr, fmt.Errorf("reading body from GitLab: %w", readErr))
+		}
+		var gb []gitlabBranch
+		if err := json.Unmarshal(body, &gb); err != nil {
+			logger.Error(err, "unmarshalling GitLab response")
+			return Branches{}, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+		}
+		for _, b := range gb {
+			branches = append(branches, Branch{Name: b.Name, SHA: b.Commit.ID})
+		}
+		if page = resp.Header.Get("X-Next-Page"); page == "" {
+			return Branches{Items: branches}, nil
+		}
+	}
+	logger.Info("listed the maximum number of branch pages", "branches", len(branches))
+
+	return Branches{Items: branches, Truncated: true}, nil
+}
+
+type gitlabMergeRequest struct {
+	IID          int      `json:"iid"`
+	Title        string   `json:"title"`
+	WebURL       string   `json:"web_url"`
+	State        string   `json:"state"`
+	SourceBranch string   `json:"source_branch"`
+	SHA          string   `json:"sha"`
+	TargetBranch string   `json:"target_branch"`
+	Labels       []string `json:"labels"`
+}
+
+func (m gitlabMergeRequest) pullRequest() PullRequest {
+	state := m.State
+	// Merge requests are briefly locked while they're being merged.
+	if m.State == "opened" || m.State == "locked" {
+		state = PullRequestOpen
+	}
+	return PullRequest{
+		Number:  m.IID,
+		Title:   m.Title,
+		URL:     m.WebURL,
+		State:   state,
+		HeadRef: m.SourceBranch,
+		HeadSHA: m.SHA,
+		BaseRef: m.TargetBranch,
+		Labels:  m.Labels,
+	}
+}
+
+// ListPullRequests lists the open merge requests in the repository, this
+// follows the pagination up to maxPullRequestPages.
+func (g GitLabPoller) ListPullRequests(ctx context.Context, repo string) ([]PullRequest, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	var pullRequests []PullRequest
+	page := "1"
+	for range maxPullRequestPages {
+		values := url.Values{
+			"state":    []string{"opened"},
+			"per_page": []string{strconv.Itoa(100)},
+			"page":     []string{page},
+		}
+		requestURL := makeGitLabProjectURL(g.endpoint, repo, "merge_requests") + "?" + values.Encode()
+		var gm []gitlabMergeRequest
+		resp, err := g.getJSON(ctx, logger, "merge requests", requestURL, "", &gm)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range gm {
+			pullRequests = append(pullRequests, m.pullRequest())
+		}
+		if page = resp.Header.Get("X-Next-Page"); page == "" {
+			return pullRequests, nil
+		}
+	}
+	logger.Info("listed the maximum number of merge request pages", "pullRequests", len(pullRequests))
+
+	return pullRequests, nil
+}
+
+// GetPullRequest gets a merge request by its IID.
+func (g GitLabPoller) GetPullRequest(ctx context.Context, repo string, number int) (PullRequest, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	requestURL := makeGitLabProjectURL(g.endpoint, repo, "merge_requests") + "/" + strconv.Itoa(number)
+	var gm gitlabMergeRequest
+	if _, err := g.getJSON(ctx, logger, "merge request", requestURL, "", &gm); err != nil {
+		return PullRequest{}, err
+	}
+	return gm.pullRequest(), nil
+}
+
+type gitlabRelease struct {
+	Name            string     `json:"name"`
+	TagName         string     `json:"tag_name"`
+	Description     string     `json:"description"`
+	ReleasedAt      *time.Time `json:"released_at"`
+	UpcomingRelease bool       `json:"upcoming_release"`
+	Commit          struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+	Links struct {
+		Self string `json:"self"`
+	} `json:"_links"`
+	Assets struct {
+		Links []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"links"`
+	} `json:"assets"`
+}
+
+// ListReleases lists the first page of releases in the repository, GitLab
+// lists the most recently released first.
+//
+// GitLab doesn't have draft releases, upcoming releases are prereleases, and
+// the assets are the release links.
+func (g GitLabPoller) ListReleases(ctx context.Context, repo, etag string) (Releases, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	values := url.Values{"per_page": []string{strconv.Itoa(100)}}
+	requestURL := makeGitLabProjectURL(g.endpoint, repo, "releases") + "?" + values.Encode()
+
+	var gr []gitlabRelease
+	resp, err := g.getJSON(ctx, logger, "releases", requestURL, etag, &gr)
+	if err != nil {
+		return Releases{}, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return Releases{ETag: etag, NotModified: true}, nil
+	}
+	releases := Releases{ETag: resp.Header.Get("ETag")}
+	for _, r := range gr {
+		release := Release{
+			Name:       r.Name,
+			Tag:        r.TagName,
+			SHA:        r.Commit.ID,
+			URL:        r.Links.Self,
+			Body:       r.Description,
+			Prerelease: r.UpcomingRelease,
+		}
+		if r.ReleasedAt != nil {
+			release.Published = *r.ReleasedAt
+		}
+		for _, link := range r.Assets.Links {
+			release.Assets = append(release.Assets, ReleaseAsset{Name: link.Name, URL: link.URL})
+		}
+		releases.Items = append(releases.Items, release)
+	}
+	return releases, nil
+}
+
+// getJSON makes a GET request to the GitLab API and decodes the JSON body
+// into v.
+//
+// If the etag is not empty, it's sent in the If-None-Match header, and the
+// body of a 304 Not Modified response isn't decoded.
+func (g GitLabPoller) getJSON(ctx context.Context, logger logr.Logger, resource, requestURL, etag string, v any) (*http.Response, error) {
+	logger.Info("getting GitLab "+resource, "url", requestURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for GitLab: %w", err)
+	}
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+	if g.authToken != "" {
+		req.Header.Add("Private-Token", g.authToken)
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "getting GitLab "+resource)
+		return nil, requestError(err, fmt.Errorf("failed to get %s: %v", resource, err))
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	if err := resp.Body.Close(); err != nil {
+		logger.Error(err, "closing request body from GitLab")
+	}
+	logger.Info("got GitLab "+resource, "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil
+	}
+	if readErr != nil {
+		return nil, requestError(readErr, fmt.Errorf("reading body from GitLab: %w", readErr))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		logger.Error(err, "unmarshalling GitLab response")
+		return nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to decode response body: %w", err))
+	}
+	return resp, nil
+}
+
+func makeGitLabListURL(endpoint, repo, resource, page string) string {
+	values := url.Values{
+		"per_page": []string{strconv.Itoa(100)},
+		"page":     []string{page},
+	}
+	return fmt.Sprintf("%s/api/v4/projects/%s/repository/%s?%s",
+		endpoint, strings.Replace(repo, "/", "%2F", -1),
+		resource, values.Encode())
 }
 
 func makeGitLabURL(endpoint, repo, ref string) string {
@@ -102,3 +411,8 @@ func makeGitLabURL(endpoint, repo, ref string) string {
 		endpoint, strings.Replace(repo, "/", "%2F", -1),
 		values.Encode())
 }
+
+func makeGitLabProjectURL(endpoint, repo, resource string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s/%s",
+		endpoint, strings.Replace(repo, "/", "%2F", -1), resource)
+}
@@ -17,11 +17,17 @@ package git
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
 
 	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
 )
 
 var _ CommitPoller = (*GitLabPoller)(nil)
@@ -97,6 +103,31 @@ func TestGitLabWithNotFoundResponse(t *testing.T) {
 	}
 }
 
+func TestGitLabWithNoCommits(t *testing.T) {
+	as := makeGitLabAPIServer(t, testToken, "/api/v4/projects/testing/repo/repository/commits", "master", testEtag, []byte(`[]`))
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if err.Error() != `no commits found for ref "master"` {
+		t.Fatal(err)
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestGitLabWithMalformedCommit(t *testing.T) {
+	as := makeGitLabAPIServer(t, testToken, "/api/v4/projects/testing/repo/repository/commits", "master", testEtag, []byte(`[{}]`))
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if !errors.Is(err, ErrMalformedResponse) {
+		t.Fatalf("got %v, want ErrMalformedResponse", err)
+	}
+}
+
 // It's impossible to distinguish between unknown repo, and bad auth token, both
 // respond with a 404.
 func TestGitLabWithBadAuthentication(t *testing.T) {
@@ -126,6 +157,186 @@ func TestGitLabWithNoAuthentication(t *testing.T) {
 
 // makeAPIServer is used during testing to create an HTTP server to return
 // fixtures if the request matches.
+func TestGitLabListTags(t *testing.T) {
+	as := makeGitLabListServer(t, testToken, "repository/tags", []string{
+		`[{"name":"v1.1.0","created_at":"2024-03-01T10:00:00Z","commit":{"id":"ed899a2f4b50b4370feeea94676502b42383c746","committed_date":"2024-02-01T10:00:00Z"}}]`,
+		`[{"name":"v1.0.0","created_at":null,"commit":{"id":"6104942438c14ec7bd21c6cd5bd995272b3faff6","committed_date":"2024-01-01T10:00:00Z"}}]`,
+	})
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, testToken)
+
+	tags, err := g.ListTags(context.TODO(), "testing/repo")
+	utils.AssertNoError(t, err)
+
+	want := []Tag{
+		{Name: "v1.1.0", SHA: "ed899a2f4b50b4370feeea94676502b42383c746", Created: time.Date(2024, time.March, 1, 10, 0, 0, 0, time.UTC)},
+		{Name: "v1.0.0", SHA: "6104942438c14ec7bd21c6cd5bd995272b3faff6", Created: time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	if diff := cmp.Diff(want, tags); diff != "" {
+		t.Errorf("ListTags() failed:\n%s", diff)
+	}
+}
+
+func TestGitLabListBranches(t *testing.T) {
+	as := makeGitLabListServer(t, testToken, "repository/branches", []string{
+		`[{"name":"main","commit":{"id":"ed899a2f4b50b4370feeea94676502b42383c746"}}]`,
+		`[{"name":"release/1.1","commit":{"id":"6104942438c14ec7bd21c6cd5bd995272b3faff6"}}]`,
+	})
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, testToken)
+
+	branches, err := g.ListBranches(context.TODO(), "testing/repo")
+	utils.AssertNoError(t, err)
+
+	want := Branches{Items: []Branch{
+		{Name: "main", SHA: "ed899a2f4b50b4370feeea94676502b42383c746"},
+		{Name: "release/1.1", SHA: "6104942438c14ec7bd21c6cd5bd995272b3faff6"},
+	}}
+	if diff := cmp.Diff(want, branches); diff != "" {
+		t.Errorf("ListBranches() failed:\n%s", diff)
+	}
+}
+
+func TestGitLabListPullRequests(t *testing.T) {
+	as := makeGitLabListServer(t, testToken, "merge_requests", []string{
+		`[{"iid":1,"title":"Add a feature","web_url":"https://gitlab.com/testing/repo/-/merge_requests/1","state":"opened","source_branch":"feature","sha":"ed899a2f4b50b4370feeea94676502b42383c746","target_branch":"main","labels":["ready"]}]`,
+		`[{"iid":2,"title":"Fix a bug","web_url":"https://gitlab.com/testing/repo/-/merge_requests/2","state":"locked","source_branch":"fix","sha":"6104942438c14ec7bd21c6cd5bd995272b3faff6","target_branch":"release/1.1","labels":[]}]`,
+	})
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, testToken)
+
+	pullRequests, err := g.ListPullRequests(context.TODO(), "testing/repo")
+	utils.AssertNoError(t, err)
+
+	want := []PullRequest{
+		{
+			Number: 1, Title: "Add a feature", URL: "https://gitlab.com/testing/repo/-/merge_requests/1", State: PullRequestOpen,
+			HeadRef: "feature", HeadSHA: "ed899a2f4b50b4370feeea94676502b42383c746", BaseRef: "main", Labels: []string{"ready"},
+		},
+		{
+			Number: 2, Title: "Fix a bug", URL: "https://gitlab.com/testing/repo/-/merge_requests/2", State: PullRequestOpen,
+			HeadRef: "fix", HeadSHA: "6104942438c14ec7bd21c6cd5bd995272b3faff6", BaseRef: "release/1.1", Labels: []string{},
+		},
+	}
+	if diff := cmp.Diff(want, pullRequests); diff != "" {
+		t.Errorf("ListPullRequests() failed:\n%s", diff)
+	}
+}
+
+func TestGitLabGetPullRequest(t *testing.T) {
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/projects/testing/repo/merge_requests/3" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if auth := r.Header.Get("Private-Token"); auth != testToken {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(`{"iid":3,"title":"Merged","state":"merged","source_branch":"done","sha":"ed899a2f4b50b4370feeea94676502b42383c746","target_branch":"main"}`)); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, testToken)
+
+	pr, err := g.GetPullRequest(context.TODO(), "testing/repo", 3)
+	utils.AssertNoError(t, err)
+
+	want := PullRequest{Number: 3, Title: "Merged", State: PullRequestMerged, HeadRef: "done", HeadSHA: "ed899a2f4b50b4370feeea94676502b42383c746", BaseRef: "main"}
+	if diff := cmp.Diff(want, pr); diff != "" {
+		t.Errorf("GetPullRequest() failed:\n%s", diff)
+	}
+}
+
+func TestGitLabListReleases(t *testing.T) {
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/projects/testing/repo/releases" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if auth := r.Header.Get("Private-Token"); auth != testToken {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("If-None-Match") == "etag-1" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", "etag-1")
+		response := `[{"name":"Release 1.10","tag_name":"v1.10.0","description":"Fixes","released_at":"2024-02-10T00:00:00Z","upcoming_release":false,"commit":{"id":"ed899a2f4b50b4370feeea94676502b42383c746"},"_links":{"self":"https://gitlab.com/testing/repo/-/releases/v1.10.0"},"assets":{"links":[{"name":"demo.tar.gz","url":"https://example.com/demo.tar.gz"}]}}]`
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, testToken)
+
+	releases, err := g.ListReleases(context.TODO(), "testing/repo", "")
+	utils.AssertNoError(t, err)
+
+	want := Releases{
+		Items: []Release{
+			{
+				Name: "Release 1.10", Tag: "v1.10.0", SHA: "ed899a2f4b50b4370feeea94676502b42383c746",
+				URL: "https://gitlab.com/testing/repo/-/releases/v1.10.0", Body: "Fixes",
+				Published: time.Date(2024, time.February, 10, 0, 0, 0, 0, time.UTC),
+				Assets:    []ReleaseAsset{{Name: "demo.tar.gz", URL: "https://example.com/demo.tar.gz"}},
+			},
+		},
+		ETag: "etag-1",
+	}
+	if diff := cmp.Diff(want, releases); diff != "" {
+		t.Errorf("ListReleases() failed:\n%s", diff)
+	}
+
+	releases, err = g.ListReleases(context.TODO(), "testing/repo", "etag-1")
+	utils.AssertNoError(t, err)
+
+	if diff := cmp.Diff(Releases{ETag: "etag-1", NotModified: true}, releases); diff != "" {
+		t.Errorf("ListReleases() with a known ETag failed:\n%s", diff)
+	}
+}
+
+func TestGitLabListTagsWithBadAuthentication(t *testing.T) {
+	as := makeGitLabListServer(t, testToken, "repository/tags", nil)
+	t.Cleanup(as.Close)
+	g := NewGitLabPoller(as.Client(), as.URL, "anotherToken")
+
+	_, err := g.ListTags(context.TODO(), "testing/repo")
+	utils.AssertErrorMatch(t, "server error: 404", err)
+}
+
+// makeGitLabListServer responds with the pages of the resource, the
+// X-Next-Page header links to the following page.
+func makeGitLabListServer(t *testing.T, authToken, resource string, pages []string) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/projects/testing/repo/"+resource {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if auth := r.Header.Get("Private-Token"); auth != authToken {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil || page < 1 || page > len(pages) {
+			t.Errorf("unexpected request for page %q", r.URL.Query().Get("page"))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if page < len(pages) {
+			w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(pages[page-1])); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+}
+
 func makeGitLabAPIServer(t *testing.T, authToken, wantPath, wantRef, etag string, response []byte) *httptest.Server {
 	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path != wantPath {
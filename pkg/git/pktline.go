@@ -0,0 +1,203 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// This implements enough of the Git pkt-line format to list the refs in a
+// repository.
+//
+// https://git-scm.com/docs/protocol-common#_pkt_line_format
+
+const (
+	pktFlush = "0000"
+	pktDelim = "0001"
+
+	maxPktLen = 65520
+)
+
+// errFlushPkt is returned when reading a flush-pkt.
+var errFlushPkt = errors.New("flush-pkt")
+
+// errDelimPkt is returned when reading a delim-pkt.
+var errDelimPkt = errors.New("delim-pkt")
+
+// remoteRef is a ref advertised by the remote Git server.
+type remoteRef struct {
+	Name string
+	SHA  string
+	// Peeled is the SHA of the commit for annotated tags.
+	Peeled string
+}
+
+// readPktLine reads a single pkt-line, the returned line has the trailing
+// newline removed.
+//
+// Special packets are returned as errors.
+func readPktLine(r io.Reader) (string, error) {
+	var lenBuf [4]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return "", err
+	}
+	switch string(lenBuf[:]) {
+	case pktFlush:
+		return "", errFlushPkt
+	case pktDelim:
+		return "", errDelimPkt
+	}
+	n, err := strconv.ParseUint(string(lenBuf[:]), 16, 16)
+	if err != nil {
+		return "", withKind(ErrMalformedResponse, fmt.Errorf("invalid pkt-line length %q: %w", lenBuf, err))
+	}
+	if n < 4 || n > maxPktLen {
+		return "", withKind(ErrMalformedResponse, fmt.Errorf("invalid pkt-line length %d", n))
+	}
+	data := make([]byte, n-4)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// writePktLine writes the string as a pkt-line.
+func writePktLine(w *bytes.Buffer, s string) {
+	fmt.Fprintf(w, "%04x%s", len(s)+4, s)
+}
+
+// writeLsRefs writes the protocol v2 ls-refs command, limiting the response to
+// the refs with the prefixes.
+func writeLsRefs(w *bytes.Buffer, prefixes []string) {
+	writePktLine(w, "command=ls-refs\n")
+	writePktLine(w, "agent="+gitUserAgent+"\n")
+	w.WriteString(pktDelim)
+	writePktLine(w, "peel\n")
+	for _, prefix := range prefixes {
+		writePktLine(w, "ref-prefix "+prefix+"\n")
+	}
+	w.WriteString(pktFlush)
+}
+
+// skipCapabilities reads the protocol v2 capability advertisement up to the
+// flush-pkt.
+func skipCapabilities(r io.Reader) error {
+	for {
+		_, err := readPktLine(r)
+		if err == errFlushPkt {
+			return nil
+		}
+		if err != nil {
+			return withKind(ErrMalformedResponse, fmt.Errorf("failed to read capability advertisement: %w", err))
+		}
+	}
+}
+
+// parseRefAdvertisement parses the protocol v0 ref advertisement, the first
+// line has the capabilities after a NUL, and peeled tags are advertised
+// separately with a "^{}" suffix.
+//
+// The service header that is sent in the smart HTTP protocol is expected to
+// have been read already.
+func parseRefAdvertisement(r io.Reader) ([]remoteRef, error) {
+	var refs []remoteRef
+	byName := map[string]int{}
+	for {
+		line, err := readPktLine(r)
+		if err == errFlushPkt {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to read ref advertisement: %w", err))
+		}
+		line, _, _ = strings.Cut(line, "\x00")
+		sha, name, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, withKind(ErrMalformedResponse, fmt.Errorf("invalid ref advertisement %q", line))
+		}
+		// An empty repository advertises the capabilities with a zero-id.
+		if name == "capabilities^{}" {
+			continue
+		}
+		if tag, ok := strings.CutSuffix(name, "^{}"); ok {
+			if i, ok := byName[tag]; ok {
+				refs[i].Peeled = sha
+			}
+			continue
+		}
+		byName[name] = len(refs)
+		refs = append(refs, remoteRef{Name: name, SHA: sha})
+	}
+}
+
+// parseLsRefs parses the response to the protocol v2 ls-refs command.
+//
+// Each line is "<oid> <refname> [symref-target:<target>] [peeled:<oid>]".
+func parseLsRefs(r io.Reader) ([]remoteRef, error) {
+	var refs []remoteRef
+	for {
+		line, err := readPktLine(r)
+		if err == errFlushPkt {
+			return refs, nil
+		}
+		if err != nil {
+			return nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to read ls-refs response: %w", err))
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, withKind(ErrMalformedResponse, fmt.Errorf("invalid ls-refs response %q", line))
+		}
+		ref := remoteRef{SHA: fields[0], Name: fields[1]}
+		for _, attr := range fields[2:] {
+			if peeled, ok := strings.CutPrefix(attr, "peeled:"); ok {
+				ref.Peeled = peeled
+			}
+		}
+		refs = append(refs, ref)
+	}
+}
+
+// refCandidates returns the full names that a ref could refer to, in the
+// order that they should be checked.
+func refCandidates(ref string) []string {
+	if ref == "HEAD" || strings.HasPrefix(ref, "refs/") {
+		return []string{ref}
+	}
+	return []string{"refs/heads/" + ref, "refs/tags/" + ref}
+}
+
+// resolveRef finds the first ref that matches the candidates for ref, and
+// returns the SHA of the commit it refers to.
+func resolveRef(refs []remoteRef, ref string) (remoteRef, string, error) {
+	for _, candidate := range refCandidates(ref) {
+		for _, r := range refs {
+			if r.Name != candidate {
+				continue
+			}
+			if r.Peeled != "" {
+				return r, r.Peeled, nil
+			}
+			return r, r.SHA, nil
+		}
+	}
+	return remoteRef{}, "", withKind(ErrNotFound, fmt.Errorf("ref %q not found", ref))
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	testMainSHA      = "c7d5f1ad4ff3e1e2e5b23b9d8f8fe6cbd8c1b3a2"
+	testFeatureSHA   = "0d4f45a9c3b8d6e5f41a2b3c4d5e6f7a8b9c0d1e"
+	testTagSHA       = "9b8a7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b"
+	testTagCommitSHA = "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b"
+)
+
+func TestReadPktLine(t *testing.T) {
+	r := strings.NewReader("000ahello\n0000" + "0001" + "0004")
+
+	line, err := readPktLine(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "hello" {
+		t.Errorf("got %q, want %q", line, "hello")
+	}
+	if _, err := readPktLine(r); err != errFlushPkt {
+		t.Errorf("got %v, want flush-pkt", err)
+	}
+	if _, err := readPktLine(r); err != errDelimPkt {
+		t.Errorf("got %v, want delim-pkt", err)
+	}
+	line, err = readPktLine(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "" {
+		t.Errorf("got %q, want an empty line", line)
+	}
+}
+
+func TestReadPktLine_errors(t *testing.T) {
+	readTests := []struct {
+		input   string
+		wantErr string
+	}{
+		{"zzzz", `invalid pkt-line length "zzzz"`},
+		{"0003", "invalid pkt-line length 3"},
+		{"000ahel", "unexpected EOF"},
+	}
+
+	for _, tt := range readTests {
+		_, err := readPktLine(strings.NewReader(tt.input))
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("readPktLine(%q) got %v, want %q", tt.input, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParseRefAdvertisement(t *testing.T) {
+	var b bytes.Buffer
+	writePktLine(&b, testMainSHA+" HEAD\x00multi_ack symref=HEAD:refs/heads/main agent=git/2.43.0\n")
+	writePktLine(&b, testMainSHA+" refs/heads/main\n")
+	writePktLine(&b, testFeatureSHA+" refs/heads/feature\n")
+	writePktLine(&b, testTagSHA+" refs/tags/v1.0.0\n")
+	writePktLine(&b, testTagCommitSHA+" refs/tags/v1.0.0^{}\n")
+	b.WriteString(pktFlush)
+
+	refs, err := parseRefAdvertisement(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []remoteRef{
+		{Name: "HEAD", SHA: testMainSHA},
+		{Name: "refs/heads/main", SHA: testMainSHA},
+		{Name: "refs/heads/feature", SHA: testFeatureSHA},
+		{Name: "refs/tags/v1.0.0", SHA: testTagSHA, Peeled: testTagCommitSHA},
+	}
+	if diff := cmp.Diff(want, refs); diff != "" {
+		t.Errorf("failed to parse refs:\n%s", diff)
+	}
+}
+
+func TestParseRefAdvertisement_empty_repository(t *testing.T) {
+	var b bytes.Buffer
+	writePktLine(&b, "0000000000000000000000000000000000000000 capabilities^{}\x00multi_ack\n")
+	b.WriteString(pktFlush)
+
+	refs, err := parseRefAdvertisement(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(refs) != 0 {
+		t.Errorf("got %v refs, want none", refs)
+	}
+}
+
+func TestParseLsRefs(t *testing.T) {
+	var b bytes.Buffer
+	writePktLine(&b, testMainSHA+" HEAD symref-target:refs/heads/main\n")
+	writePktLine(&b, testMainSHA+" refs/heads/main\n")
+	writePktLine(&b, testTagSHA+" refs/tags/v1.0.0 peeled:"+testTagCommitSHA+"\n")
+	b.WriteString(pktFlush)
+
+	refs, err := parseLsRefs(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []remoteRef{
+		{Name: "HEAD", SHA: testMainSHA},
+		{Name: "refs/heads/main", SHA: testMainSHA},
+		{Name: "refs/tags/v1.0.0", SHA: testTagSHA, Peeled: testTagCommitSHA},
+	}
+	if diff := cmp.Diff(want, refs); diff != "" {
+		t.Errorf("failed to parse refs:\n%s", diff)
+	}
+}
+
+func TestResolveRef(t *testing.T) {
+	refs := []remoteRef{
+		{Name: "HEAD", SHA: testMainSHA},
+		{Name: "refs/heads/main", SHA: testMainSHA},
+		{Name: "refs/heads/v1.0.0", SHA: testFeatureSHA},
+		{Name: "refs/heads/feature", SHA: testFeatureSHA},
+		{Name: "refs/tags/v1.0.0", SHA: testTagSHA, Peeled: testTagCommitSHA},
+		{Name: "refs/tags/v2.0.0", SHA: testMainSHA},
+	}
+
+	resolveTests := []struct {
+		ref      string
+		wantName string
+		wantSHA  string
+	}{
+		{"main", "refs/heads/main", testMainSHA},
+		{"HEAD", "HEAD", testMainSHA},
+		{"refs/heads/feature", "refs/heads/feature", testFeatureSHA},
+		// Branches take precedence over tags.
+		{"v1.0.0", "refs/heads/v1.0.0", testFeatureSHA},
+		{"refs/tags/v1.0.0", "refs/tags/v1.0.0", testTagCommitSHA},
+		{"v2.0.0", "refs/tags/v2.0.0", testMainSHA},
+	}
+
+	for _, tt := range resolveTests {
+		t.Run(tt.ref, func(t *testing.T) {
+			ref, sha, err := resolveRef(refs, tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ref.Name != tt.wantName {
+				t.Errorf("got ref %q, want %q", ref.Name, tt.wantName)
+			}
+			if sha != tt.wantSHA {
+				t.Errorf("got SHA %q, want %q", sha, tt.wantSHA)
+			}
+		})
+	}
+}
+
+func TestResolveRef_not_found(t *testing.T) {
+	_, _, err := resolveRef([]remoteRef{{Name: "refs/heads/main", SHA: testMainSHA}}, "main2")
+	if err.Error() != `ref "main2" not found` {
+		t.Fatal(err)
+	}
+}
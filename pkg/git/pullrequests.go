@@ -0,0 +1,68 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+)
+
+// maxPullRequestPages limits the number of pages of pull requests that are
+// listed.
+const maxPullRequestPages = 10
+
+// These are the states of a pull request.
+const (
+	PullRequestOpen   = "open"
+	PullRequestClosed = "closed"
+	PullRequestMerged = "merged"
+)
+
+// PullRequest is a GitHub pull request or a GitLab merge request.
+type PullRequest struct {
+	Number int
+	Title  string
+	URL    string
+	// State is one of PullRequestOpen, PullRequestClosed or
+	// PullRequestMerged.
+	State   string
+	HeadRef string
+	HeadSHA string
+	// BaseRef is the target branch.
+	BaseRef string
+	Labels  []string
+}
+
+// Commit returns the pull request as the body of an event.
+func (p PullRequest) Commit() Commit {
+	return Commit{
+		"number":  p.Number,
+		"title":   p.Title,
+		"url":     p.URL,
+		"state":   p.State,
+		"headRef": p.HeadRef,
+		"headSHA": p.HeadSHA,
+		"baseRef": p.BaseRef,
+		"labels":  p.Labels,
+	}
+}
+
+// PullRequestLister implementations can list the open pull requests in a
+// repository, and get a pull request in any state.
+type PullRequestLister interface {
+	ListPullRequests(ctx context.Context, repo string) ([]PullRequest, error)
+	GetPullRequest(ctx context.Context, repo string, number int) (PullRequest, error)
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultRateLimitDelay is used when a request is rate limited and the server
+// doesn't say when the limit resets.
+const defaultRateLimitDelay = time.Minute
+
+// Reset values below this are a number of seconds rather than a Unix time.
+const minUnixReset = 1_000_000_000
+
+// RateLimit is the rate limit state reported by the server.
+type RateLimit struct {
+	// Limit is the number of requests allowed in the window, this is zero if
+	// the server didn't report it.
+	Limit int
+	// Remaining is the number of requests left in the window.
+	Remaining int
+	// Reset is when the window resets.
+	Reset time.Time
+}
+
+// Exhausted returns true if there are no requests left before the reset.
+func (r RateLimit) Exhausted(now time.Time) bool {
+	return r.Remaining <= 0 && r.Reset.After(now)
+}
+
+// ParseRateLimit parses the rate limit from the response headers.
+//
+// GitHub sends X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+// and GitLab sends RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset,
+// the reset can be a Unix time or a number of seconds.
+//
+// A Retry-After header means that no requests are allowed until then.
+func ParseRateLimit(h http.Header, now time.Time) (RateLimit, bool) {
+	var limit RateLimit
+	var found bool
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(h.Get(prefix + "Remaining"))
+		if err != nil {
+			continue
+		}
+		limit.Remaining = remaining
+		limit.Limit, _ = strconv.Atoi(h.Get(prefix + "Limit"))
+		if reset, err := strconv.ParseInt(h.Get(prefix+"Reset"), 10, 64); err == nil {
+			if reset < minUnixReset {
+				limit.Reset = now.Add(time.Duration(reset) * time.Second)
+			} else {
+				limit.Reset = time.Unix(reset, 0)
+			}
+		}
+		found = true
+		break
+	}
+
+	if retryAfter, ok := parseRetryAfter(h.Get("Retry-After"), now); ok {
+		limit.Remaining = 0
+		if retryAfter.After(limit.Reset) {
+			limit.Reset = retryAfter
+		}
+		found = true
+	}
+
+	return limit, found
+}
+
+func parseRetryAfter(s string, now time.Time) (time.Time, bool) {
+	if s == "" {
+		return time.Time{}, false
+	}
+	if seconds, err := strconv.Atoi(s); err == nil {
+		return now.Add(time.Duration(seconds) * time.Second), true
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// RateLimitError is returned when the server rejects a request because the
+// rate limit has been exceeded.
+type RateLimitError struct {
+	StatusCode int
+	RateLimit
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("server error: %d: rate limit exceeded until %s", e.StatusCode, e.Reset.UTC().Format(time.RFC3339))
+}
+
+// Is returns true if the target is ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// checkRateLimit returns a RateLimitError if the response is a 429, or a 403
+// because the rate limit is exhausted.
+//
+// GitHub responds with a 403 for both rate limits and missing permissions, so
+// a 403 is only a rate limit when the headers say so.
+func checkRateLimit(resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		return nil
+	}
+	now := time.Now()
+	limit, ok := ParseRateLimit(resp.Header, now)
+	if resp.StatusCode == http.StatusForbidden && (!ok || limit.Remaining > 0) {
+		return nil
+	}
+	if !limit.Reset.After(now) {
+		limit.Reset = now.Add(defaultRateLimitDelay)
+	}
+	limit.Remaining = 0
+
+	return &RateLimitError{StatusCode: resp.StatusCode, RateLimit: limit}
+}
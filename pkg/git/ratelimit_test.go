@@ -0,0 +1,175 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Date(2024, time.March, 12, 9, 0, 0, 0, time.UTC)
+	reset := now.Add(30 * time.Minute)
+
+	parseTests := []struct {
+		name    string
+		headers map[string]string
+		want    RateLimit
+		wantOK  bool
+	}{
+		{
+			"GitHub",
+			map[string]string{"X-RateLimit-Limit": "5000", "X-RateLimit-Remaining": "4999", "X-RateLimit-Reset": "1710235800"},
+			RateLimit{Limit: 5000, Remaining: 4999, Reset: reset},
+			true,
+		},
+		{
+			"GitLab",
+			map[string]string{"RateLimit-Limit": "2000", "RateLimit-Remaining": "0", "RateLimit-Reset": "1710235800"},
+			RateLimit{Limit: 2000, Remaining: 0, Reset: reset},
+			true,
+		},
+		{
+			"reset in seconds",
+			map[string]string{"RateLimit-Limit": "2000", "RateLimit-Remaining": "10", "RateLimit-Reset": "1800"},
+			RateLimit{Limit: 2000, Remaining: 10, Reset: reset},
+			true,
+		},
+		{
+			"Retry-After in seconds",
+			map[string]string{"Retry-After": "1800"},
+			RateLimit{Reset: reset},
+			true,
+		},
+		{
+			"Retry-After as a date",
+			map[string]string{"X-RateLimit-Remaining": "20", "Retry-After": "Tue, 12 Mar 2024 09:30:00 GMT"},
+			RateLimit{Reset: reset},
+			true,
+		},
+		{
+			"no headers",
+			map[string]string{},
+			RateLimit{},
+			false,
+		},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{}
+			for k, v := range tt.headers {
+				h.Set(k, v)
+			}
+
+			got, ok := ParseRateLimit(h, now)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseRateLimit() got %v, want %v", ok, tt.wantOK)
+			}
+			if diff := cmp.Diff(tt.want, got, cmp.Comparer(func(x, y time.Time) bool { return x.Equal(y) })); diff != "" {
+				t.Errorf("failed to parse rate limit:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPollersWithRateLimits(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	pollerTests := []struct {
+		name       string
+		status     int
+		headers    map[string]string
+		makePoller func(c *http.Client, endpoint string) CommitPoller
+		wantReset  time.Time
+	}{
+		{
+			"GitHub primary rate limit",
+			http.StatusForbidden,
+			map[string]string{"X-RateLimit-Limit": "60", "X-RateLimit-Remaining": "0", "X-RateLimit-Reset": unixHeader(reset)},
+			func(c *http.Client, endpoint string) CommitPoller { return NewGitHubPoller(c, endpoint, testToken) },
+			reset,
+		},
+		{
+			"GitHub secondary rate limit",
+			http.StatusTooManyRequests,
+			map[string]string{"X-RateLimit-Remaining": "20", "Retry-After": "3600"},
+			func(c *http.Client, endpoint string) CommitPoller { return NewGitHubPoller(c, endpoint, testToken) },
+			reset,
+		},
+		{
+			"GitLab",
+			http.StatusTooManyRequests,
+			map[string]string{"RateLimit-Limit": "60", "RateLimit-Remaining": "0", "RateLimit-Reset": unixHeader(reset)},
+			func(c *http.Client, endpoint string) CommitPoller { return NewGitLabPoller(c, endpoint, testToken) },
+			reset,
+		},
+	}
+
+	for _, tt := range pollerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			t.Cleanup(as.Close)
+			g := tt.makePoller(as.Client(), as.URL)
+
+			_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+
+			var rateLimited *RateLimitError
+			if !errors.As(err, &rateLimited) {
+				t.Fatalf("got %v, want a RateLimitError", err)
+			}
+			if rateLimited.StatusCode != tt.status {
+				t.Errorf("got status %d, want %d", rateLimited.StatusCode, tt.status)
+			}
+			if d := rateLimited.Reset.Sub(tt.wantReset).Abs(); d > 2*time.Second {
+				t.Errorf("got reset %s, want %s", rateLimited.Reset, tt.wantReset)
+			}
+		})
+	}
+}
+
+// A 403 with remaining requests is a permissions error.
+func TestGitHubWithForbiddenResponse(t *testing.T) {
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	t.Cleanup(as.Close)
+	g := NewGitHubPoller(as.Client(), as.URL, testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{Ref: "master"})
+	if err.Error() != "server error: 403" {
+		t.Fatal(err)
+	}
+}
+
+func unixHeader(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Release is a GitHub or GitLab release.
+type Release struct {
+	Name string
+	Tag  string
+	// SHA is the SHA of the released commit, this is only provided by
+	// GitLab.
+	SHA        string
+	URL        string
+	Body       string
+	Draft      bool
+	Prerelease bool
+	// Published is zero for draft releases.
+	Published time.Time
+	Assets    []ReleaseAsset
+}
+
+// ReleaseAsset is a file that is attached to a release.
+type ReleaseAsset struct {
+	Name        string
+	URL         string
+	ContentType string
+	Size        int64
+}
+
+// Commit returns the release as the body of an event.
+func (r Release) Commit() Commit {
+	assets := []map[string]any{}
+	for _, asset := range r.Assets {
+		a := map[string]any{"name": asset.Name, "url": asset.URL}
+		if asset.ContentType != "" {
+			a["contentType"] = asset.ContentType
+		}
+		if asset.Size != 0 {
+			a["size"] = asset.Size
+		}
+		assets = append(assets, a)
+	}
+	commit := Commit{
+		"name":       r.Name,
+		"tag":        r.Tag,
+		"url":        r.URL,
+		"body":       r.Body,
+		"draft":      r.Draft,
+		"prerelease": r.Prerelease,
+		"assets":     assets,
+	}
+	if r.SHA != "" {
+		commit["sha"] = r.SHA
+	}
+	if !r.Published.IsZero() {
+		commit["published"] = r.Published.UTC().Format(time.RFC3339)
+	}
+	return commit
+}
+
+// Releases are the releases listed from a repository.
+type Releases struct {
+	// Items are the releases, newest first.
+	Items []Release
+	// ETag identifies this list of releases.
+	ETag string
+	// NotModified is true if the releases haven't changed since the ETag
+	// that was provided, the Items are empty.
+	NotModified bool
+}
+
+// ReleaseLister implementations can list the releases in a repository.
+//
+// If the etag is not empty, it's sent in the If-None-Match header, and if the
+// releases haven't changed, NotModified is true.
+type ReleaseLister interface {
+	ListReleases(ctx context.Context, repo, etag string) (Releases, error)
+}
+
+var _ CommitPoller = (*ReleasePoller)(nil)
+
+// ReleasePoller is a CommitPoller that selects the newest release that isn't
+// excluded by the ReleasePolicy.
+//
+// The polled status has the tag of the selected release, the SHA if the
+// provider has it, when it was published, and the ETag of the releases, the
+// commit is the release with the list of assets.
+type ReleasePoller struct {
+	lister ReleaseLister
+	policy pollingv1alpha1.ReleasePolicy
+}
+
+// NewReleasePoller creates and returns a new ReleasePoller.
+func NewReleasePoller(lister ReleaseLister, policy pollingv1alpha1.ReleasePolicy) *ReleasePoller {
+	return &ReleasePoller{lister: lister, policy: policy}
+}
+
+func (p ReleasePoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("repo", repo)
+	releases, err := p.lister.ListReleases(ctx, repo, pr.ETag)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	if releases.NotModified {
+		return pr, nil, nil
+	}
+	release, ok := p.selectRelease(releases.Items)
+	if !ok {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrNotFound, fmt.Errorf("none of the %d releases match the release policy", len(releases.Items)))
+	}
+	logger.Info("poll complete", "tag", release.Tag, "sha", release.SHA)
+	status := pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: release.SHA, Tag: release.Tag, ETag: releases.ETag}
+	if !release.Published.IsZero() {
+		status.Published = &metav1.Time{Time: release.Published}
+	}
+	// The releases can change without changing the selected release e.g.
+	// when a draft is created, the new ETag is returned without a commit.
+	if release.Tag == pr.Tag && release.SHA == pr.SHA {
+		return status, nil, nil
+	}
+	// When the newest release is deleted, the previous release is selected,
+	// the last release is kept rather than dispatching an older release.
+	if pr.Published != nil && status.Published != nil && status.Published.Before(pr.Published) {
+		logger.Info("ignoring release older than the last release", "tag", release.Tag, "lastTag", pr.Tag)
+		last := pr
+		last.ETag = releases.ETag
+		return last, nil, nil
+	}
+
+	return status, release.Commit(), nil
+}
+
+// selectRelease returns the first release that isn't excluded by the policy,
+// and false if all the releases are excluded.
+func (p ReleasePoller) selectRelease(releases []Release) (Release, bool) {
+	for _, release := range releases {
+		if p.policy.ExcludeDrafts && release.Draft {
+			continue
+		}
+		if p.policy.ExcludePrereleases && release.Prerelease {
+			continue
+		}
+		return release, true
+	}
+	return Release{}, false
+}
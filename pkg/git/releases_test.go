@@ -0,0 +1,167 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+)
+
+var (
+	_ ReleaseLister = (*GitHubPoller)(nil)
+	_ ReleaseLister = (*GitLabPoller)(nil)
+)
+
+var testReleases = []Release{
+	{Name: "Next", Tag: "v2.0.0", Draft: true},
+	{Name: "Release candidate", Tag: "v2.0.0-rc.1", Prerelease: true, Published: time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)},
+	{
+		Name: "Release 1.10", Tag: "v1.10.0", URL: "https://github.com/testing/repo/releases/tag/v1.10.0", Body: "Fixes",
+		Published: time.Date(2024, time.February, 10, 0, 0, 0, 0, time.UTC),
+		Assets:    []ReleaseAsset{{Name: "demo.tar.gz", URL: "https://github.com/testing/repo/releases/download/v1.10.0/demo.tar.gz", ContentType: "application/gzip", Size: 1024}},
+	},
+}
+
+func TestReleasePoller(t *testing.T) {
+	pollTests := []struct {
+		name          string
+		policy        pollingv1alpha1.ReleasePolicy
+		wantTag       string
+		wantPublished *metav1.Time
+	}{
+		{"no policy", pollingv1alpha1.ReleasePolicy{}, "v2.0.0", nil},
+		{"excluding drafts", pollingv1alpha1.ReleasePolicy{ExcludeDrafts: true}, "v2.0.0-rc.1", &metav1.Time{Time: testReleases[1].Published}},
+		{"excluding drafts and prereleases", pollingv1alpha1.ReleasePolicy{ExcludeDrafts: true, ExcludePrereleases: true}, "v1.10.0", &metav1.Time{Time: testReleases[2].Published}},
+	}
+
+	for _, tt := range pollTests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewReleasePoller(fakeReleaseLister{releases: Releases{Items: testReleases, ETag: "etag-1"}}, tt.policy)
+
+			polled, commit, err := p.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{})
+			utils.AssertNoError(t, err)
+
+			want := pollingv1alpha1.PollStatus{Tag: tt.wantTag, ETag: "etag-1", Published: tt.wantPublished}
+			if diff := cmp.Diff(want, polled); diff != "" {
+				t.Errorf("Poll() failed:\n%s", diff)
+			}
+			if commit["tag"] != tt.wantTag {
+				t.Errorf("Poll() got commit tag %v, want %s", commit["tag"], tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestReleasePollerCommit(t *testing.T) {
+	p := NewReleasePoller(fakeReleaseLister{releases: Releases{Items: testReleases}}, pollingv1alpha1.ReleasePolicy{ExcludeDrafts: true, ExcludePrereleases: true})
+
+	_, commit, err := p.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{})
+	utils.AssertNoError(t, err)
+
+	want := Commit{
+		"name":       "Release 1.10",
+		"tag":        "v1.10.0",
+		"url":        "https://github.com/testing/repo/releases/tag/v1.10.0",
+		"body":       "Fixes",
+		"draft":      false,
+		"prerelease": false,
+		"published":  "2024-02-10T00:00:00Z",
+		"assets": []map[string]any{
+			{"name": "demo.tar.gz", "url": "https://github.com/testing/repo/releases/download/v1.10.0/demo.tar.gz", "contentType": "application/gzip", "size": int64(1024)},
+		},
+	}
+	if diff := cmp.Diff(want, commit); diff != "" {
+		t.Errorf("Poll() failed:\n%s", diff)
+	}
+}
+
+func TestReleasePollerWithUnchangedRelease(t *testing.T) {
+	p := NewReleasePoller(fakeReleaseLister{releases: Releases{Items: testReleases, ETag: "etag-2"}}, pollingv1alpha1.ReleasePolicy{ExcludeDrafts: true})
+	status := pollingv1alpha1.PollStatus{Tag: "v2.0.0-rc.1", ETag: "etag-1"}
+
+	polled, commit, err := p.Poll(context.TODO(), "testing/repo", status)
+	utils.AssertNoError(t, err)
+
+	want := pollingv1alpha1.PollStatus{Tag: "v2.0.0-rc.1", ETag: "etag-2", Published: &metav1.Time{Time: testReleases[1].Published}}
+	if diff := cmp.Diff(want, polled); diff != "" {
+		t.Errorf("Poll() failed:\n%s", diff)
+	}
+	if commit != nil {
+		t.Errorf("Poll() got commit %v, want nil", commit)
+	}
+}
+
+func TestReleasePollerWithDeletedRelease(t *testing.T) {
+	// The release candidate was deleted, and the older release is selected.
+	p := NewReleasePoller(fakeReleaseLister{releases: Releases{Items: testReleases[2:], ETag: "etag-2"}}, pollingv1alpha1.ReleasePolicy{})
+	status := pollingv1alpha1.PollStatus{Tag: "v2.0.0-rc.1", ETag: "etag-1", Published: &metav1.Time{Time: testReleases[1].Published}}
+
+	polled, commit, err := p.Poll(context.TODO(), "testing/repo", status)
+	utils.AssertNoError(t, err)
+
+	want := pollingv1alpha1.PollStatus{Tag: "v2.0.0-rc.1", ETag: "etag-2", Published: &metav1.Time{Time: testReleases[1].Published}}
+	if diff := cmp.Diff(want, polled); diff != "" {
+		t.Errorf("Poll() failed:\n%s", diff)
+	}
+	if commit != nil {
+		t.Errorf("Poll() got commit %v, want nil", commit)
+	}
+}
+
+func TestReleasePollerWithNotModifiedReleases(t *testing.T) {
+	lister := fakeReleaseLister{releases: Releases{ETag: "etag-1", NotModified: true}}
+	p := NewReleasePoller(lister, pollingv1alpha1.ReleasePolicy{})
+	status := pollingv1alpha1.PollStatus{Tag: "v1.10.0", ETag: "etag-1"}
+
+	polled, commit, err := p.Poll(context.TODO(), "testing/repo", status)
+	utils.AssertNoError(t, err)
+
+	if diff := cmp.Diff(status, polled); diff != "" {
+		t.Errorf("Poll() failed:\n%s", diff)
+	}
+	if commit != nil {
+		t.Errorf("Poll() got commit %v, want nil", commit)
+	}
+}
+
+func TestReleasePollerWithNoMatchingReleases(t *testing.T) {
+	p := NewReleasePoller(fakeReleaseLister{releases: Releases{Items: testReleases[:2]}}, pollingv1alpha1.ReleasePolicy{ExcludeDrafts: true, ExcludePrereleases: true})
+
+	_, _, err := p.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{})
+
+	utils.AssertErrorMatch(t, "none of the 2 releases match the release policy", err)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+type fakeReleaseLister struct {
+	releases Releases
+	err      error
+}
+
+func (f fakeReleaseLister) ListReleases(ctx context.Context, repo, etag string) (Releases, error) {
+	return f.releases, f.err
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+const (
+	gitUserAgent          = "git/gitpoller-controller"
+	uploadPackAdvertType  = "application/x-git-upload-pack-advertisement"
+	uploadPackRequestType = "application/x-git-upload-pack-request"
+	uploadPackResultType  = "application/x-git-upload-pack-result"
+	protocolV2Header      = "version=2"
+	protocolV2Line        = "000eversion 2\n"
+	uploadPackServiceLine = "# service=git-upload-pack"
+)
+
+// SmartHTTPPoller polls any Git server that supports the smart HTTP protocol.
+//
+// This is equivalent to git ls-remote, it requests the refs using protocol v2
+// where the server supports it, and falls back to the v0 ref advertisement.
+//
+// Requests are authenticated with basic auth, if no username is provided,
+// "git" is used.
+type SmartHTTPPoller struct {
+	client    *http.Client
+	endpoint  string
+	username  string
+	authToken string
+}
+
+// NewSmartHTTPPoller creates and returns a new Git smart HTTP poller.
+func NewSmartHTTPPoller(c *http.Client, endpoint, username, authToken string) *SmartHTTPPoller {
+	return &SmartHTTPPoller{client: c, endpoint: endpoint, username: username, authToken: authToken}
+}
+
+func (g SmartHTTPPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	repoURL, err := url.JoinPath(g.endpoint, repo)
+	if err != nil {
+		logger.Error(err, "polling Git repo")
+		return pollingv1alpha1.PollStatus{}, nil, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	logger.Info("polling Git repo", "url", repoURL)
+	refs, err := g.listRefs(ctx, logger, repoURL, refCandidates(pr.Ref))
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	ref, sha, err := resolveRef(refs, pr.Ref)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha}, Commit{"ref": ref.Name, "sha": sha}, nil
+}
+
+// ListBranches lists the branches advertised by the server.
+func (g SmartHTTPPoller) ListBranches(ctx context.Context, repo string) (Branches, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	repoURL, err := url.JoinPath(g.endpoint, repo)
+	if err != nil {
+		return Branches{}, fmt.Errorf("failed to make the request URL: %w", err)
+	}
+	logger.Info("listing Git branches", "url", repoURL)
+	refs, err := g.listRefs(ctx, logger, repoURL, []string{branchPrefix})
+	if err != nil {
+		return Branches{}, err
+	}
+	return Branches{Items: branchesFromRefs(refs)}, nil
+}
+
+func (g SmartHTTPPoller) listRefs(ctx context.Context, logger logr.Logger, repoURL string, prefixes []string) ([]remoteRef, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, repoURL+"/info/refs?service=git-upload-pack", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for Git: %w", err)
+	}
+	req.Header.Add("Git-Protocol", protocolV2Header)
+	g.addHeaders(req)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "polling Git repo")
+		return nil, requestError(err, fmt.Errorf("failed to get refs: %v", err))
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from Git")
+		}
+	}()
+	logger.Info("polled Git repo", "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != uploadPackAdvertType {
+		return nil, withKind(ErrMalformedResponse, fmt.Errorf("server does not support the smart HTTP protocol, got content-type %q", ct))
+	}
+
+	r := bufio.NewReader(resp.Body)
+	line, err := readPktLine(r)
+	if err != nil {
+		return nil, withKind(ErrMalformedResponse, fmt.Errorf("failed to read service header: %w", err))
+	}
+	if line != uploadPackServiceLine {
+		return nil, withKind(ErrMalformedResponse, fmt.Errorf("invalid service header %q", line))
+	}
+	if _, err := readPktLine(r); err != errFlushPkt {
+		return nil, withKind(ErrMalformedResponse, fmt.Errorf("invalid service header, expected flush-pkt: %v", err))
+	}
+
+	if peeked, _ := r.Peek(len(protocolV2Line)); string(peeked) != protocolV2Line {
+		logger.Info("server does not support protocol v2, using the ref advertisement")
+		return parseRefAdvertisement(r)
+	}
+
+	return g.lsRefs(ctx, logger, repoURL, prefixes)
+}
+
+// lsRefs sends the protocol v2 ls-refs command, limiting the response to the
+// refs with the prefixes.
+func (g SmartHTTPPoller) lsRefs(ctx context.Context, logger logr.Logger, repoURL string, prefixes []string) ([]remoteRef, error) {
+	var body bytes.Buffer
+	writeLsRefs(&body, prefixes)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, repoURL+"/git-upload-pack", &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for Git: %w", err)
+	}
+	req.Header.Add("Git-Protocol", protocolV2Header)
+	req.Header.Add("Content-Type", uploadPackRequestType)
+	req.Header.Add("Accept", uploadPackResultType)
+	g.addHeaders(req)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Error(err, "listing refs in Git repo")
+		return nil, requestError(err, fmt.Errorf("failed to list refs: %v", err))
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			logger.Error(err, "closing request body from Git")
+		}
+	}()
+	logger.Info("listed refs in Git repo", "status", resp.StatusCode)
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	return parseLsRefs(resp.Body)
+}
+
+func (g SmartHTTPPoller) addHeaders(req *http.Request) {
+	req.Header.Set("User-Agent", gitUserAgent)
+	if g.authToken != "" {
+		username := g.username
+		if username == "" {
+			username = "git"
+		}
+		req.SetBasicAuth(username, g.authToken)
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ CommitPoller = (*SmartHTTPPoller)(nil)
+
+var testRemoteRefs = []remoteRef{
+	{Name: "HEAD", SHA: testMainSHA},
+	{Name: "refs/heads/main", SHA: testMainSHA},
+	{Name: "refs/heads/feature", SHA: testFeatureSHA},
+	{Name: "refs/tags/v1.0.0", SHA: testTagSHA, Peeled: testTagCommitSHA},
+}
+
+func TestSmartHTTPPoller(t *testing.T) {
+	for _, v2 := range []bool{true, false} {
+		name := "protocol v0"
+		if v2 {
+			name = "protocol v2"
+		}
+		t.Run(name, func(t *testing.T) {
+			pollTests := []struct {
+				ref        string
+				wantSHA    string
+				wantCommit Commit
+			}{
+				{"main", testMainSHA, Commit{"ref": "refs/heads/main", "sha": testMainSHA}},
+				{"refs/heads/feature", testFeatureSHA, Commit{"ref": "refs/heads/feature", "sha": testFeatureSHA}},
+				{"v1.0.0", testTagCommitSHA, Commit{"ref": "refs/tags/v1.0.0", "sha": testTagCommitSHA}},
+			}
+
+			as := makeSmartHTTPServer(t, v2, basicAuth("git", testToken), "/testing/repo.git", testRemoteRefs)
+			t.Cleanup(as.Close)
+			g := NewSmartHTTPPoller(as.Client(), as.URL, "", testToken)
+
+			for _, tt := range pollTests {
+				polled, commit, err := g.Poll(context.TODO(), "testing/repo.git", pollingv1alpha1.PollStatus{Ref: tt.ref})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want := pollingv1alpha1.PollStatus{Ref: tt.ref, SHA: tt.wantSHA}
+				if diff := cmp.Diff(want, polled); diff != "" {
+					t.Errorf("incorrect poll status:\n%s", diff)
+				}
+				if diff := cmp.Diff(tt.wantCommit, commit); diff != "" {
+					t.Errorf("incorrect commit:\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestSmartHTTPPollerListBranches(t *testing.T) {
+	for _, v2 := range []bool{true, false} {
+		name := "protocol v0"
+		if v2 {
+			name = "protocol v2"
+		}
+		t.Run(name, func(t *testing.T) {
+			as := makeSmartHTTPServer(t, v2, basicAuth("git", testToken), "/testing/repo.git", testRemoteRefs)
+			t.Cleanup(as.Close)
+			g := NewSmartHTTPPoller(as.Client(), as.URL, "", testToken)
+
+			branches, err := g.ListBranches(context.TODO(), "testing/repo.git")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := Branches{Items: []Branch{{Name: "main", SHA: testMainSHA}, {Name: "feature", SHA: testFeatureSHA}}}
+			if diff := cmp.Diff(want, branches); diff != "" {
+				t.Errorf("incorrect branches:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSmartHTTPPollerWithUsername(t *testing.T) {
+	as := makeSmartHTTPServer(t, true, basicAuth(testUsername, testToken), "/testing/repo.git", testRemoteRefs)
+	t.Cleanup(as.Close)
+	g := NewSmartHTTPPoller(as.Client(), as.URL, testUsername, testToken)
+
+	polled, _, err := g.Poll(context.TODO(), "testing/repo.git", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if polled.SHA != testMainSHA {
+		t.Errorf("Poll() SHA got %s, want %s", polled.SHA, testMainSHA)
+	}
+}
+
+func TestSmartHTTPPollerWithUnknownRef(t *testing.T) {
+	as := makeSmartHTTPServer(t, true, basicAuth("git", testToken), "/testing/repo.git", testRemoteRefs)
+	t.Cleanup(as.Close)
+	g := NewSmartHTTPPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo.git", pollingv1alpha1.PollStatus{Ref: "unknown"})
+	if err.Error() != `ref "unknown" not found` {
+		t.Fatal(err)
+	}
+}
+
+func TestSmartHTTPPollerWithNotFoundResponse(t *testing.T) {
+	as := makeSmartHTTPServer(t, true, basicAuth("git", testToken), "/testing/repo.git", testRemoteRefs)
+	t.Cleanup(as.Close)
+	g := NewSmartHTTPPoller(as.Client(), as.URL, "", testToken)
+
+	_, _, err := g.Poll(context.TODO(), "testing/testing.git", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != "server error: 404" {
+		t.Fatal(err)
+	}
+}
+
+func TestSmartHTTPPollerWithBadAuthentication(t *testing.T) {
+	as := makeSmartHTTPServer(t, true, basicAuth("git", testToken), "/testing/repo.git", testRemoteRefs)
+	t.Cleanup(as.Close)
+	g := NewSmartHTTPPoller(as.Client(), as.URL, "", "anotherToken")
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo.git", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != "server error: 401" {
+		t.Fatal(err)
+	}
+}
+
+func TestSmartHTTPPollerWithDumbServer(t *testing.T) {
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if _, err := w.Write([]byte(testMainSHA + "\trefs/heads/main\n")); err != nil {
+			t.Errorf("failed to write response: %s", err)
+		}
+	}))
+	t.Cleanup(as.Close)
+	g := NewSmartHTTPPoller(as.Client(), as.URL, "", "")
+
+	_, _, err := g.Poll(context.TODO(), "testing/repo.git", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != `server does not support the smart HTTP protocol, got content-type "text/plain"` {
+		t.Fatal(err)
+	}
+}
+
+// makeSmartHTTPServer is used during testing to create an HTTP server that
+// implements enough of the Git smart HTTP protocol to list the refs.
+//
+// If v2 is true, the server will respond to clients requesting protocol v2.
+func makeSmartHTTPServer(t *testing.T, v2 bool, authorized func(*http.Request) bool, repoPath string, refs []remoteRef) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), "git/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		useV2 := v2 && r.Header.Get("Git-Protocol") == "version=2"
+
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == repoPath+"/info/refs":
+			if r.URL.Query().Get("service") != "git-upload-pack" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			var b bytes.Buffer
+			writePktLine(&b, "# service=git-upload-pack\n")
+			b.WriteString(pktFlush)
+			if useV2 {
+				writePktLine(&b, "version 2\n")
+				writePktLine(&b, "agent=git/2.43.0\n")
+				writePktLine(&b, "ls-refs=unborn\n")
+				writePktLine(&b, "fetch=shallow\n")
+				b.WriteString(pktFlush)
+			} else {
+				for i, ref := range refs {
+					line := ref.SHA + " " + ref.Name
+					if i == 0 {
+						line += "\x00multi_ack agent=git/2.43.0"
+					}
+					writePktLine(&b, line+"\n")
+					if ref.Peeled != "" {
+						writePktLine(&b, ref.Peeled+" "+ref.Name+"^{}\n")
+					}
+				}
+				b.WriteString(pktFlush)
+			}
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+			if _, err := w.Write(b.Bytes()); err != nil {
+				t.Errorf("failed to write response: %s", err)
+			}
+		case r.Method == http.MethodPost && r.URL.Path == repoPath+"/git-upload-pack" && useV2:
+			if r.Header.Get("Content-Type") != "application/x-git-upload-pack-request" {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+			prefixes, err := readLsRefsCommand(r.Body)
+			if err != nil {
+				t.Errorf("failed to read ls-refs command: %s", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			var b bytes.Buffer
+			for _, ref := range refs {
+				if !matchesPrefix(ref.Name, prefixes) {
+					continue
+				}
+				line := ref.SHA + " " + ref.Name
+				if ref.Peeled != "" {
+					line += " peeled:" + ref.Peeled
+				}
+				writePktLine(&b, line+"\n")
+			}
+			b.WriteString(pktFlush)
+			w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+			if _, err := w.Write(b.Bytes()); err != nil {
+				t.Errorf("failed to write response: %s", err)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+// readLsRefsCommand parses the ls-refs command and returns the ref-prefixes.
+func readLsRefsCommand(r io.Reader) ([]string, error) {
+	command, err := readPktLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if command != "command=ls-refs" {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var prefixes []string
+	for {
+		line, err := readPktLine(r)
+		if err == errFlushPkt {
+			return prefixes, nil
+		}
+		if err == errDelimPkt {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if prefix, ok := strings.CutPrefix(line, "ref-prefix "); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+}
+
+func matchesPrefix(name string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
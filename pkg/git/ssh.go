@@ -0,0 +1,271 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	defaultSSHUser = "git"
+
+	// defaultSSHTimeout limits how long listing the refs can take when the
+	// context has no deadline, a server that accepts the connection and
+	// stalls would otherwise block forever.
+	defaultSSHTimeout = 30 * time.Second
+)
+
+// SSHPoller polls any Git server over SSH.
+//
+// This is equivalent to git ls-remote, it runs git-upload-pack on the remote
+// server, and reads the ref advertisement.
+//
+// The endpoint is expected to be in the form ssh://user@host:port, if no
+// user is provided, "git" is used.
+//
+// The server's host key must be in the known hosts.
+type SSHPoller struct {
+	endpoint   string
+	privateKey []byte
+	knownHosts []byte
+	timeout    time.Duration
+}
+
+// NewSSHPoller creates and returns a new Git SSH poller.
+func NewSSHPoller(endpoint string, privateKey, knownHosts []byte) *SSHPoller {
+	return &SSHPoller{endpoint: endpoint, privateKey: privateKey, knownHosts: knownHosts, timeout: defaultSSHTimeout}
+}
+
+func (g SSHPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	config, addr, err := g.clientConfig()
+	if err != nil {
+		logger.Error(err, "polling Git repo over SSH")
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	logger.Info("polling Git repo over SSH", "addr", addr)
+	refs, err := g.listRefs(ctx, logger, config, addr, repo, refCandidates(pr.Ref))
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	ref, sha, err := resolveRef(refs, pr.Ref)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	logger.Info("poll complete", "ref", pr.Ref, "sha", sha)
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: sha}, Commit{"ref": ref.Name, "sha": sha}, nil
+}
+
+// ListBranches lists the branches advertised by the server.
+func (g SSHPoller) ListBranches(ctx context.Context, repo string) (Branches, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("endpoint", g.endpoint, "repo", repo)
+	config, addr, err := g.clientConfig()
+	if err != nil {
+		logger.Error(err, "listing Git branches over SSH")
+		return Branches{}, err
+	}
+	logger.Info("listing Git branches over SSH", "addr", addr)
+	refs, err := g.listRefs(ctx, logger, config, addr, repo, []string{branchPrefix})
+	if err != nil {
+		return Branches{}, err
+	}
+	return Branches{Items: branchesFromRefs(refs)}, nil
+}
+
+func (g SSHPoller) clientConfig() (*ssh.ClientConfig, string, error) {
+	parsed, err := url.Parse(g.endpoint)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse the endpoint: %w", err)
+	}
+	user := defaultSSHUser
+	if parsed.User != nil && parsed.User.Username() != "" {
+		user = parsed.User.Username()
+	}
+	port := parsed.Port()
+	if port == "" {
+		port = "22"
+	}
+
+	if len(g.privateKey) == 0 {
+		return nil, "", errors.New("no private key provided for SSH")
+	}
+	signer, err := ssh.ParsePrivateKey(g.privateKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse the private key: %w", err)
+	}
+	hostKeyCallback, err := knownHostsCallback(g.knownHosts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		ClientVersion:   "SSH-2.0-gitpoller-controller",
+	}, net.JoinHostPort(parsed.Hostname(), port), nil
+}
+
+func (g SSHPoller) listRefs(ctx context.Context, logger logr.Logger, config *ssh.ClientConfig, addr, repo string, prefixes []string) ([]remoteRef, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		logger.Error(err, "polling Git repo over SSH")
+		return nil, requestError(err, fmt.Errorf("failed to connect to %s: %w", addr, err))
+	}
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to set the connection deadline: %w", err)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		logger.Error(err, "polling Git repo over SSH")
+		err = fmt.Errorf("failed to establish SSH connection to %s: %w", addr, err)
+		if strings.Contains(err.Error(), "unable to authenticate") {
+			return nil, withKind(ErrUnauthorized, err)
+		}
+		return nil, requestError(err, err)
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	defer func() {
+		if err := client.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			logger.Error(err, "closing SSH connection")
+		}
+	}()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SSH session: %w", err)
+	}
+	defer session.Close()
+	// Servers only support protocol v2 over SSH if they accept the
+	// environment variable, otherwise this is ignored.
+	_ = session.Setenv("GIT_PROTOCOL", protocolV2Header)
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSH session stdin: %w", err)
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSH session stdout: %w", err)
+	}
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	if err := session.Start("git-upload-pack " + quoteArg(repo)); err != nil {
+		return nil, fmt.Errorf("failed to start git-upload-pack: %w", err)
+	}
+
+	refs, err := readRefs(bufio.NewReader(stdout), stdin, prefixes)
+	if err != nil {
+		// Wait for the command to exit so that stderr has been copied.
+		_ = session.Wait()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, requestError(err, err)
+	}
+	logger.Info("listed refs in Git repo over SSH", "count", len(refs))
+	return refs, nil
+}
+
+// readRefs reads the refs from git-upload-pack, using the ls-refs command if
+// the server responds with protocol v2.
+//
+// The request is terminated once the refs have been read, as no objects are
+// needed.
+func readRefs(r *bufio.Reader, w io.WriteCloser, prefixes []string) ([]remoteRef, error) {
+	defer w.Close()
+	if peeked, _ := r.Peek(len(protocolV2Line)); string(peeked) != protocolV2Line {
+		refs, err := parseRefAdvertisement(r)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, pktFlush); err != nil {
+			return nil, fmt.Errorf("failed to end the request: %w", err)
+		}
+		return refs, nil
+	}
+
+	if err := skipCapabilities(r); err != nil {
+		return nil, err
+	}
+	var command bytes.Buffer
+	writeLsRefs(&command, prefixes)
+	if _, err := w.Write(command.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to send the ls-refs command: %w", err)
+	}
+	refs, err := parseLsRefs(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, pktFlush); err != nil {
+		return nil, fmt.Errorf("failed to end the request: %w", err)
+	}
+	return refs, nil
+}
+
+// knownHostsCallback parses the known hosts, the x/crypto knownhosts package
+// only reads files, so these are written to a temporary file.
+func knownHostsCallback(knownHosts []byte) (ssh.HostKeyCallback, error) {
+	if len(bytes.TrimSpace(knownHosts)) == 0 {
+		return nil, errors.New("no known hosts provided for SSH")
+	}
+	f, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create known hosts file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(knownHosts); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write known hosts file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write known hosts file: %w", err)
+	}
+	callback, err := knownhosts.New(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse known hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// quoteArg quotes the argument for the remote shell.
+func quoteArg(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,389 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var _ CommitPoller = (*SSHPoller)(nil)
+
+func TestSSHPoller(t *testing.T) {
+	for _, v2 := range []bool{true, false} {
+		name := "protocol v0"
+		if v2 {
+			name = "protocol v2"
+		}
+		t.Run(name, func(t *testing.T) {
+			pollTests := []struct {
+				ref        string
+				wantSHA    string
+				wantCommit Commit
+			}{
+				{"main", testMainSHA, Commit{"ref": "refs/heads/main", "sha": testMainSHA}},
+				{"refs/heads/feature", testFeatureSHA, Commit{"ref": "refs/heads/feature", "sha": testFeatureSHA}},
+				{"v1.0.0", testTagCommitSHA, Commit{"ref": "refs/tags/v1.0.0", "sha": testTagCommitSHA}},
+			}
+
+			clientKey, privateKey := makeSSHKey(t)
+			ss := makeSSHServer(t, v2, clientKey.PublicKey(), "/testing/repo.git", testRemoteRefs)
+			g := NewSSHPoller("ssh://git@"+ss.addr, privateKey, ss.knownHosts)
+
+			for _, tt := range pollTests {
+				polled, commit, err := g.Poll(context.TODO(), "/testing/repo.git", pollingv1alpha1.PollStatus{Ref: tt.ref})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want := pollingv1alpha1.PollStatus{Ref: tt.ref, SHA: tt.wantSHA}
+				if diff := cmp.Diff(want, polled); diff != "" {
+					t.Errorf("incorrect poll status:\n%s", diff)
+				}
+				if diff := cmp.Diff(tt.wantCommit, commit); diff != "" {
+					t.Errorf("incorrect commit:\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestSSHPollerListBranches(t *testing.T) {
+	for _, v2 := range []bool{true, false} {
+		name := "protocol v0"
+		if v2 {
+			name = "protocol v2"
+		}
+		t.Run(name, func(t *testing.T) {
+			clientKey, privateKey := makeSSHKey(t)
+			ss := makeSSHServer(t, v2, clientKey.PublicKey(), "/testing/repo.git", testRemoteRefs)
+			g := NewSSHPoller("ssh://git@"+ss.addr, privateKey, ss.knownHosts)
+
+			branches, err := g.ListBranches(context.TODO(), "/testing/repo.git")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := Branches{Items: []Branch{{Name: "main", SHA: testMainSHA}, {Name: "feature", SHA: testFeatureSHA}}}
+			if diff := cmp.Diff(want, branches); diff != "" {
+				t.Errorf("incorrect branches:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSSHPollerWithUnknownRef(t *testing.T) {
+	clientKey, privateKey := makeSSHKey(t)
+	ss := makeSSHServer(t, true, clientKey.PublicKey(), "/testing/repo.git", testRemoteRefs)
+	g := NewSSHPoller("ssh://git@"+ss.addr, privateKey, ss.knownHosts)
+
+	_, _, err := g.Poll(context.TODO(), "/testing/repo.git", pollingv1alpha1.PollStatus{Ref: "unknown"})
+	if err.Error() != `ref "unknown" not found` {
+		t.Fatal(err)
+	}
+}
+
+func TestSSHPollerWithNotFoundRepository(t *testing.T) {
+	clientKey, privateKey := makeSSHKey(t)
+	ss := makeSSHServer(t, true, clientKey.PublicKey(), "/testing/repo.git", testRemoteRefs)
+	g := NewSSHPoller("ssh://git@"+ss.addr, privateKey, ss.knownHosts)
+
+	_, _, err := g.Poll(context.TODO(), "/testing/testing.git", pollingv1alpha1.PollStatus{Ref: "main"})
+	if err.Error() != "failed to read ref advertisement: EOF: fatal: '/testing/testing.git' does not appear to be a git repository" {
+		t.Fatal(err)
+	}
+}
+
+func TestSSHPollerWithStalledServer(t *testing.T) {
+	_, privateKey := makeSSHKey(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	t.Cleanup(func() {
+		close(stop)
+		l.Close()
+	})
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		// Accept the connection and never respond.
+		<-stop
+		conn.Close()
+	}()
+	hostKey, _ := makeSSHKey(t)
+	knownHosts := knownhosts.Line([]string{knownhosts.Normalize(l.Addr().String())}, hostKey.PublicKey())
+	g := NewSSHPoller("ssh://git@"+l.Addr().String(), privateKey, []byte(knownHosts))
+	g.timeout = 100 * time.Millisecond
+
+	_, _, err = g.Poll(context.TODO(), "/testing/repo.git", pollingv1alpha1.PollStatus{Ref: "main"})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got %v, want a timeout error", err)
+	}
+}
+
+func TestSSHPollerWithBadAuthentication(t *testing.T) {
+	clientKey, _ := makeSSHKey(t)
+	_, privateKey := makeSSHKey(t)
+	ss := makeSSHServer(t, true, clientKey.PublicKey(), "/testing/repo.git", testRemoteRefs)
+	g := NewSSHPoller("ssh://git@"+ss.addr, privateKey, ss.knownHosts)
+
+	_, _, err := g.Poll(context.TODO(), "/testing/repo.git", pollingv1alpha1.PollStatus{Ref: "main"})
+	utils.AssertErrorMatch(t, "unable to authenticate", err)
+}
+
+func TestSSHPollerWithUnknownHostKey(t *testing.T) {
+	clientKey, privateKey := makeSSHKey(t)
+	ss := makeSSHServer(t, true, clientKey.PublicKey(), "/testing/repo.git", testRemoteRefs)
+	otherKey, _ := makeSSHKey(t)
+	knownHosts := knownhosts.Line([]string{knownhosts.Normalize(ss.addr)}, otherKey.PublicKey())
+	g := NewSSHPoller("ssh://git@"+ss.addr, privateKey, []byte(knownHosts))
+
+	_, _, err := g.Poll(context.TODO(), "/testing/repo.git", pollingv1alpha1.PollStatus{Ref: "main"})
+	utils.AssertErrorMatch(t, "knownhosts: key mismatch", err)
+}
+
+func TestSSHPollerWithMissingCredentials(t *testing.T) {
+	_, privateKey := makeSSHKey(t)
+	credentialTests := []struct {
+		privateKey []byte
+		knownHosts []byte
+		wantErr    string
+	}{
+		{nil, []byte("example.com ssh-ed25519 AAAA"), "no private key provided for SSH"},
+		{[]byte("not a key"), []byte("example.com ssh-ed25519 AAAA"), "failed to parse the private key"},
+		{privateKey, nil, "no known hosts provided for SSH"},
+	}
+
+	for _, tt := range credentialTests {
+		g := NewSSHPoller("ssh://git@example.com", tt.privateKey, tt.knownHosts)
+
+		_, _, err := g.Poll(context.TODO(), "/testing/repo.git", pollingv1alpha1.PollStatus{Ref: "main"})
+		utils.AssertErrorMatch(t, tt.wantErr, err)
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	quoteTests := []struct {
+		s    string
+		want string
+	}{
+		{"org/repo.git", "'org/repo.git'"},
+		{"/org/it's.git", `'/org/it'\''s.git'`},
+	}
+
+	for _, tt := range quoteTests {
+		if got := quoteArg(tt.s); got != tt.want {
+			t.Errorf("quoteArg(%q) got %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+type testSSHServer struct {
+	addr       string
+	knownHosts []byte
+}
+
+// makeSSHServer is used during testing to create an SSH server that stands in
+// for sshd and git-shell, it runs enough of git-upload-pack to list the refs.
+//
+// If v2 is true, the server accepts the GIT_PROTOCOL environment variable.
+func makeSSHServer(t *testing.T, v2 bool, authorizedKey ssh.PublicKey, repoPath string, refs []remoteRef) testSSHServer {
+	hostKey, _ := makeSSHKey(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "git" && bytes.Equal(key.Marshal(), authorizedKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown public key for %q", conn.User())
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSHConn(conn, config, v2, repoPath, refs)
+		}
+	}()
+
+	addr := l.Addr().String()
+	return testSSHServer{
+		addr:       addr,
+		knownHosts: []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey.PublicKey())),
+	}
+}
+
+func serveSSHConn(conn net.Conn, config *ssh.ServerConfig, v2 bool, repoPath string, refs []remoteRef) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go serveUploadPack(channel, requests, v2, repoPath, refs)
+	}
+}
+
+func serveUploadPack(channel ssh.Channel, requests <-chan *ssh.Request, v2 bool, repoPath string, refs []remoteRef) {
+	defer channel.Close()
+	useV2 := false
+	for req := range requests {
+		switch req.Type {
+		case "env":
+			var env struct{ Name, Value string }
+			if err := ssh.Unmarshal(req.Payload, &env); err != nil || !v2 {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			useV2 = env.Name == "GIT_PROTOCOL" && env.Value == "version=2"
+			_ = req.Reply(true, nil)
+		case "exec":
+			var cmd struct{ Command string }
+			if err := ssh.Unmarshal(req.Payload, &cmd); err != nil {
+				_ = req.Reply(false, nil)
+				return
+			}
+			_ = req.Reply(true, nil)
+			status := runUploadPack(channel, cmd.Command, useV2, repoPath, refs)
+			_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func runUploadPack(channel ssh.Channel, command string, v2 bool, repoPath string, refs []remoteRef) uint32 {
+	if command != "git-upload-pack "+quoteArg(repoPath) {
+		path := strings.Trim(strings.TrimPrefix(command, "git-upload-pack "), "'")
+		fmt.Fprintf(channel.Stderr(), "fatal: '%s' does not appear to be a git repository\n", path)
+		return 128
+	}
+
+	var b bytes.Buffer
+	r := bufio.NewReader(channel)
+	if !v2 {
+		for i, ref := range refs {
+			line := ref.SHA + " " + ref.Name
+			if i == 0 {
+				line += "\x00multi_ack agent=git/2.43.0"
+			}
+			writePktLine(&b, line+"\n")
+			if ref.Peeled != "" {
+				writePktLine(&b, ref.Peeled+" "+ref.Name+"^{}\n")
+			}
+		}
+		b.WriteString(pktFlush)
+		if _, err := channel.Write(b.Bytes()); err != nil {
+			return 1
+		}
+		// The client sends a flush-pkt when it doesn't want any objects.
+		if _, err := readPktLine(r); err != errFlushPkt {
+			return 1
+		}
+		return 0
+	}
+
+	writePktLine(&b, "version 2\n")
+	writePktLine(&b, "agent=git/2.43.0\n")
+	writePktLine(&b, "ls-refs=unborn\n")
+	b.WriteString(pktFlush)
+	if _, err := channel.Write(b.Bytes()); err != nil {
+		return 1
+	}
+	prefixes, err := readLsRefsCommand(r)
+	if err != nil {
+		return 1
+	}
+	b.Reset()
+	for _, ref := range refs {
+		if !matchesPrefix(ref.Name, prefixes) {
+			continue
+		}
+		line := ref.SHA + " " + ref.Name
+		if ref.Peeled != "" {
+			line += " peeled:" + ref.Peeled
+		}
+		writePktLine(&b, line+"\n")
+	}
+	b.WriteString(pktFlush)
+	if _, err := channel.Write(b.Bytes()); err != nil {
+		return 1
+	}
+	if _, err := readPktLine(r); err != errFlushPkt {
+		return 1
+	}
+	return 0
+}
+
+// makeSSHKey creates a new key, and returns the signer and the PEM encoded
+// private key.
+func makeSSHKey(t *testing.T) (ssh.Signer, []byte) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/go-logr/logr"
+)
+
+// maxTagPages limits the number of pages of tags that are listed.
+const maxTagPages = 10
+
+// Tag is a tag in a repository.
+type Tag struct {
+	Name string
+	// SHA is the SHA of the tagged commit.
+	SHA string
+	// Created is the date of the tagger for annotated tags, and the commit
+	// date for lightweight tags.
+	Created time.Time
+}
+
+// TagLister implementations can list the tags in a repository.
+type TagLister interface {
+	ListTags(ctx context.Context, repo string) ([]Tag, error)
+}
+
+var _ CommitPoller = (*TagPoller)(nil)
+
+// TagPoller is a CommitPoller that selects a tag from the tags in the
+// repository with a TagPolicy.
+//
+// The polled status has the selected tag and the SHA of the tagged commit,
+// and the commit has the tag, SHA and creation date.
+type TagPoller struct {
+	lister     TagLister
+	constraint *semver.Constraints
+	pattern    *regexp.Regexp
+	latest     bool
+}
+
+// NewTagPoller creates and returns a new TagPoller, an error is returned if
+// the policy is invalid.
+func NewTagPoller(lister TagLister, policy pollingv1alpha1.TagPolicy) (*TagPoller, error) {
+	if policy.SemVer == "" && policy.Pattern == "" && !policy.Latest {
+		return nil, errors.New("the tag policy has no semver, pattern or latest")
+	}
+	if policy.SemVer != "" && policy.Latest {
+		return nil, errors.New("the tag policy can't have both semver and latest")
+	}
+	p := &TagPoller{lister: lister, latest: policy.Latest}
+	if policy.SemVer != "" {
+		constraint, err := semver.NewConstraint(policy.SemVer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid semver range %q: %w", policy.SemVer, err)
+		}
+		p.constraint = constraint
+	}
+	if policy.Pattern != "" {
+		pattern, err := regexp.Compile(policy.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag pattern %q: %w", policy.Pattern, err)
+		}
+		p.pattern = pattern
+	}
+
+	return p, nil
+}
+
+func (p TagPoller) Poll(ctx context.Context, repo string, pr pollingv1alpha1.PollStatus) (pollingv1alpha1.PollStatus, Commit, error) {
+	logger := logr.FromContextOrDiscard(ctx).WithValues("repo", repo)
+	tags, err := p.lister.ListTags(ctx, repo)
+	if err != nil {
+		return pollingv1alpha1.PollStatus{}, nil, err
+	}
+	tag, ok := p.selectTag(tags)
+	if !ok {
+		return pollingv1alpha1.PollStatus{}, nil, withKind(ErrNotFound, fmt.Errorf("none of the %d tags match the tag policy", len(tags)))
+	}
+	logger.Info("poll complete", "tag", tag.Name, "sha", tag.SHA)
+	if tag.Name == pr.Tag && tag.SHA == pr.SHA {
+		return pr, nil, nil
+	}
+
+	commit := Commit{"tag": tag.Name, "sha": tag.SHA}
+	if !tag.Created.IsZero() {
+		commit["created"] = tag.Created.UTC().Format(time.RFC3339)
+	}
+	return pollingv1alpha1.PollStatus{Ref: pr.Ref, SHA: tag.SHA, Tag: tag.Name}, commit, nil
+}
+
+// selectTag returns the highest tag that matches the policy, and false if no
+// tags match.
+func (p TagPoller) selectTag(tags []Tag) (Tag, bool) {
+	var selected Tag
+	var selectedVersion *semver.Version
+	found := false
+	for _, tag := range tags {
+		if p.pattern != nil && !p.pattern.MatchString(tag.Name) {
+			continue
+		}
+		switch {
+		case p.constraint != nil:
+			version, err := semver.NewVersion(tag.Name)
+			if err != nil || !p.constraint.Check(version) {
+				continue
+			}
+			if found && !version.GreaterThan(selectedVersion) {
+				continue
+			}
+			selectedVersion = version
+		case p.latest:
+			// Tags created at the same time are ordered by name.
+			if found && (tag.Created.Before(selected.Created) || (tag.Created.Equal(selected.Created) && tag.Name <= selected.Name)) {
+				continue
+			}
+		default:
+			if found && tag.Name <= selected.Name {
+				continue
+			}
+		}
+		selected, found = tag, true
+	}
+
+	return selected, found
+}
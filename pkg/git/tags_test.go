@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package git
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	pollingv1alpha1 "github.com/gitops-tools/gitpoller-controller/api/v1alpha1"
+	"github.com/gitops-tools/gitpoller-controller/test/utils"
+)
+
+var (
+	_ TagLister = (*GitHubPoller)(nil)
+	_ TagLister = (*GitLabPoller)(nil)
+)
+
+var testTags = []Tag{
+	{Name: "v1.9.0", SHA: "sha-v1.9.0", Created: time.Date(2024, time.January, 10, 0, 0, 0, 0, time.UTC)},
+	{Name: "v1.10.0", SHA: "sha-v1.10.0", Created: time.Date(2024, time.February, 10, 0, 0, 0, 0, time.UTC)},
+	{Name: "v2.0.0-rc.1", SHA: "sha-v2.0.0-rc.1", Created: time.Date(2024, time.March, 10, 0, 0, 0, 0, time.UTC)},
+	{Name: "v1.9.1", SHA: "sha-v1.9.1", Created: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	{Name: "nightly", SHA: "sha-nightly", Created: time.Date(2024, time.March, 11, 0, 0, 0, 0, time.UTC)},
+}
+
+func TestTagPoller(t *testing.T) {
+	pollTests := []struct {
+		name    string
+		policy  pollingv1alpha1.TagPolicy
+		wantTag string
+	}{
+		{"semver range", pollingv1alpha1.TagPolicy{SemVer: ">=1.0.0 <2.0.0"}, "v1.10.0"},
+		{"semver range with prereleases", pollingv1alpha1.TagPolicy{SemVer: ">=1.0.0-0"}, "v2.0.0-rc.1"},
+		{"semver range and pattern", pollingv1alpha1.TagPolicy{SemVer: "1.x", Pattern: `^v1\.9\.`}, "v1.9.1"},
+		{"pattern", pollingv1alpha1.TagPolicy{Pattern: `^v1\.`}, "v1.9.1"},
+		{"latest", pollingv1alpha1.TagPolicy{Latest: true}, "nightly"},
+		{"latest and pattern", pollingv1alpha1.TagPolicy{Latest: true, Pattern: `^v`}, "v2.0.0-rc.1"},
+	}
+
+	for _, tt := range pollTests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewTagPoller(fakeTagLister{tags: testTags}, tt.policy)
+			utils.AssertNoError(t, err)
+
+			polled, commit, err := p.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{})
+			utils.AssertNoError(t, err)
+
+			want := pollingv1alpha1.PollStatus{Tag: tt.wantTag, SHA: "sha-" + tt.wantTag}
+			if diff := cmp.Diff(want, polled); diff != "" {
+				t.Errorf("Poll() failed:\n%s", diff)
+			}
+			if commit["tag"] != tt.wantTag {
+				t.Errorf("Poll() got commit tag %v, want %s", commit["tag"], tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestTagPollerWithUnchangedTag(t *testing.T) {
+	p, err := NewTagPoller(fakeTagLister{tags: testTags}, pollingv1alpha1.TagPolicy{SemVer: "1.x"})
+	utils.AssertNoError(t, err)
+	status := pollingv1alpha1.PollStatus{Ref: "main", Tag: "v1.10.0", SHA: "sha-v1.10.0"}
+
+	polled, commit, err := p.Poll(context.TODO(), "testing/repo", status)
+	utils.AssertNoError(t, err)
+
+	if diff := cmp.Diff(status, polled); diff != "" {
+		t.Errorf("Poll() failed:\n%s", diff)
+	}
+	if commit != nil {
+		t.Errorf("Poll() got commit %v, want nil", commit)
+	}
+}
+
+func TestTagPollerWithNoMatchingTags(t *testing.T) {
+	p, err := NewTagPoller(fakeTagLister{tags: testTags}, pollingv1alpha1.TagPolicy{SemVer: ">=3.0.0"})
+	utils.AssertNoError(t, err)
+
+	_, _, err = p.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{})
+
+	utils.AssertErrorMatch(t, "none of the 5 tags match the tag policy", err)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestTagPollerWithListError(t *testing.T) {
+	p, err := NewTagPoller(fakeTagLister{err: &StatusError{StatusCode: 401}}, pollingv1alpha1.TagPolicy{Latest: true})
+	utils.AssertNoError(t, err)
+
+	_, _, err = p.Poll(context.TODO(), "testing/repo", pollingv1alpha1.PollStatus{})
+
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("got %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestNewTagPollerErrors(t *testing.T) {
+	errorTests := []struct {
+		name   string
+		policy pollingv1alpha1.TagPolicy
+		want   string
+	}{
+		{"empty policy", pollingv1alpha1.TagPolicy{}, "the tag policy has no semver, pattern or latest"},
+		{"semver and latest", pollingv1alpha1.TagPolicy{SemVer: "1.x", Latest: true}, "the tag policy can't have both semver and latest"},
+		{"invalid semver range", pollingv1alpha1.TagPolicy{SemVer: "one"}, `invalid semver range "one"`},
+		{"invalid pattern", pollingv1alpha1.TagPolicy{Pattern: "v1("}, `invalid tag pattern "v1\("`},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTagPoller(fakeTagLister{}, tt.policy)
+			utils.AssertErrorMatch(t, tt.want, err)
+		})
+	}
+}
+
+type fakeTagLister struct {
+	tags []Tag
+	err  error
+}
+
+func (f fakeTagLister) ListTags(ctx context.Context, repo string) ([]Tag, error) {
+	return f.tags, f.err
+}